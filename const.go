@@ -3,7 +3,9 @@ package cryptopals
 import "math/big"
 
 var (
+	big1 = big.NewInt(1)
 	big2 = big.NewInt(2)
+	big3 = big.NewInt(3)
 	big5 = big.NewInt(5)
 	big6 = big.NewInt(6)
 )
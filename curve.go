@@ -0,0 +1,430 @@
+package cryptopals
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Point is a point on an elliptic curve in affine coordinates.
+// The zero Point, with nil coordinates, is the identity.
+type Point struct {
+	X, Y *big.Int
+}
+
+// Identity returns the point at infinity.
+func Identity() *Point {
+	return &Point{}
+}
+
+// NewPoint returns the point (x, y).
+func NewPoint(x, y *big.Int) *Point {
+	return &Point{X: new(big.Int).Set(x), Y: new(big.Int).Set(y)}
+}
+
+// IsIdentity reports whether p is the point at infinity.
+func (p *Point) IsIdentity() bool {
+	return p.X == nil
+}
+
+// Equal reports whether p and q are the same point.
+func (p *Point) Equal(q *Point) bool {
+	if p.IsIdentity() || q.IsIdentity() {
+		return p.IsIdentity() && q.IsIdentity()
+	}
+	return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0
+}
+
+// String returns p as "(x, y)", or "O" for the identity.
+func (p *Point) String() string {
+	if p.IsIdentity() {
+		return "O"
+	}
+	return fmt.Sprintf("(%v, %v)", p.X, p.Y)
+}
+
+// Curve is the short Weierstrass curve y^2 = x^3 + Ax + B over the
+// integers mod P. G is a base point of prime order N, and Order is
+// the number of points on the curve. G and N may be nil if the
+// curve has no designated base point.
+type Curve struct {
+	P, A, B *big.Int
+	G       *Point
+	N       *big.Int
+	Order   *big.Int
+}
+
+// Curve59 returns the curve from Challenge 59.
+func Curve59() *Curve {
+	return &Curve{
+		P: mustBigInt("233970423115425145524320034830162017933"),
+		A: big.NewInt(-95051),
+		B: big.NewInt(11279326),
+		G: &Point{
+			X: big.NewInt(182),
+			Y: mustBigInt("85518893674295321206118380980485522083"),
+		},
+		N:     mustBigInt("29246302889428143187362802287225875743"),
+		Order: mustBigInt("233970423115425145498902418297807005944"),
+	}
+}
+
+// IsOnCurve reports whether p is on the curve.
+func (c *Curve) IsOnCurve(p *Point) bool {
+	if p.IsIdentity() {
+		return true
+	}
+	if p.X.Sign() < 0 || p.X.Cmp(c.P) >= 0 || p.Y.Sign() < 0 || p.Y.Cmp(c.P) >= 0 {
+		return false
+	}
+	lhs := new(big.Int).Mul(p.Y, p.Y)
+	lhs.Mod(lhs, c.P)
+	return lhs.Cmp(c.rhs(p.X)) == 0
+}
+
+// rhs returns x^3 + Ax + B mod P.
+func (c *Curve) rhs(x *big.Int) *big.Int {
+	res := new(big.Int).Mul(x, x)
+	res.Add(res, c.A).Mul(res, x).Add(res, c.B)
+	return res.Mod(res, c.P)
+}
+
+// Neg returns -p.
+func (c *Curve) Neg(p *Point) *Point {
+	if p.IsIdentity() {
+		return Identity()
+	}
+	y := new(big.Int).Neg(p.Y)
+	return &Point{X: new(big.Int).Set(p.X), Y: y.Mod(y, c.P)}
+}
+
+// Add returns p + q. Like most implementations, it never uses B, so
+// it happily adds points from other curves that share P and A.
+func (c *Curve) Add(p, q *Point) *Point {
+	switch {
+	case p.IsIdentity():
+		return c.copy(q)
+	case q.IsIdentity():
+		return c.copy(p)
+	}
+
+	var (
+		m   = new(big.Int)
+		den = new(big.Int)
+	)
+	if p.X.Cmp(q.X) == 0 {
+		sum := new(big.Int).Add(p.Y, q.Y)
+		if sum.Mod(sum, c.P).Sign() == 0 {
+			return Identity()
+		}
+		// p == q, so use the tangent line.
+		m.Mul(p.X, p.X).Mul(m, big3).Add(m, c.A)
+		den.Lsh(p.Y, 1)
+	} else {
+		m.Sub(q.Y, p.Y)
+		den.Sub(q.X, p.X)
+	}
+	den.Mod(den, c.P).ModInverse(den, c.P)
+	m.Mul(m, den).Mod(m, c.P)
+
+	x := new(big.Int).Mul(m, m)
+	x.Sub(x, p.X).Sub(x, q.X).Mod(x, c.P)
+	y := new(big.Int).Sub(p.X, x)
+	y.Mul(y, m).Sub(y, p.Y).Mod(y, c.P)
+	return &Point{X: x, Y: y}
+}
+
+// Double returns 2p.
+func (c *Curve) Double(p *Point) *Point {
+	return c.Add(p, p)
+}
+
+// ScalarMult returns kp. The scalar k must be non-negative.
+func (c *Curve) ScalarMult(p *Point, k *big.Int) *Point {
+	res := Identity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		res = c.Double(res)
+		if k.Bit(i) == 1 {
+			res = c.Add(res, p)
+		}
+	}
+	return res
+}
+
+// ScalarBaseMult returns kG.
+func (c *Curve) ScalarBaseMult(k *big.Int) *Point {
+	return c.ScalarMult(c.G, k)
+}
+
+// RandomPoint returns a random point on the curve other than the
+// identity.
+func (c *Curve) RandomPoint() (*Point, error) {
+	for {
+		x, err := rand.Int(rand.Reader, c.P)
+		if err != nil {
+			return nil, err
+		}
+		y := new(big.Int).ModSqrt(c.rhs(x), c.P)
+		if y == nil {
+			continue
+		}
+		return &Point{X: x, Y: y}, nil
+	}
+}
+
+// PointOfOrder returns a random point of prime order r. The prime
+// r must divide the curve's Order.
+func (c *Curve) PointOfOrder(r *big.Int) (*Point, error) {
+	// Clear every other factor of the order, and then multiply by r
+	// until the next step would reach the identity. Multiplying by
+	// Order/r alone can miss if r^2 divides the order.
+	var (
+		e = new(big.Int).Set(c.Order)
+		m = new(big.Int)
+	)
+	for {
+		q, rem := new(big.Int).QuoRem(e, r, m)
+		if rem.Sign() != 0 {
+			break
+		}
+		e = q
+	}
+
+	for {
+		p, err := c.RandomPoint()
+		if err != nil {
+			return nil, err
+		}
+		if p = c.ScalarMult(p, e); p.IsIdentity() {
+			continue
+		}
+		for {
+			q := c.ScalarMult(p, r)
+			if q.IsIdentity() {
+				return p, nil
+			}
+			p = q
+		}
+	}
+}
+
+// copy returns a copy of p.
+func (c *Curve) copy(p *Point) *Point {
+	if p.IsIdentity() {
+		return Identity()
+	}
+	return NewPoint(p.X, p.Y)
+}
+
+// Bytes returns the fixed-length encoding x || y of p, or nil for
+// the identity.
+func (c *Curve) Bytes(p *Point) []byte {
+	if p.IsIdentity() {
+		return nil
+	}
+	n := (c.P.BitLen() + 7) / 8
+	b := make([]byte, 2*n)
+	p.X.FillBytes(b[:n])
+	p.Y.FillBytes(b[n:])
+	return b
+}
+
+// ParsePoint parses the encoding from Bytes. It doesn't check that
+// the point is on c.
+func (c *Curve) ParsePoint(b []byte) (*Point, error) {
+	if len(b) == 0 {
+		return Identity(), nil
+	}
+	n := (c.P.BitLen() + 7) / 8
+	if len(b) != 2*n {
+		return nil, fmt.Errorf("invalid point length %d", len(b))
+	}
+	return &Point{
+		X: new(big.Int).SetBytes(b[:n]),
+		Y: new(big.Int).SetBytes(b[n:]),
+	}, nil
+}
+
+// Group returns the group of points on c, for generic discrete log
+// algorithms. Its elements are *Point, and Int returns the x
+// coordinate, or 0 for the identity.
+func (c *Curve) Group() Group {
+	return curveGroup{c}
+}
+
+type curveGroup struct {
+	c *Curve
+}
+
+func (g curveGroup) Mul(x, y GroupElement) GroupElement {
+	return g.c.Add(x.(*Point), y.(*Point))
+}
+
+func (g curveGroup) Exp(x GroupElement, k *big.Int) GroupElement {
+	return g.c.ScalarMult(x.(*Point), k)
+}
+
+func (g curveGroup) Equal(x, y GroupElement) bool {
+	return x.(*Point).Equal(y.(*Point))
+}
+
+func (g curveGroup) Int(x GroupElement) *big.Int {
+	if p := x.(*Point); !p.IsIdentity() {
+		return p.X
+	}
+	return new(big.Int)
+}
+
+// MontgomeryCurve is the Montgomery curve Bv^2 = u^3 + Au^2 + u over
+// the integers mod P. U is the u coordinate of a base point of prime
+// order N, and Order is the number of points on the curve.
+type MontgomeryCurve struct {
+	P, A, B *big.Int
+	U       *big.Int
+	N       *big.Int
+	Order   *big.Int
+}
+
+// Curve60 returns the curve from Challenge 60. It's the same group
+// as Curve59 in a different form.
+func Curve60() *MontgomeryCurve {
+	return &MontgomeryCurve{
+		P:     mustBigInt("233970423115425145524320034830162017933"),
+		A:     big.NewInt(534),
+		B:     big.NewInt(1),
+		U:     big.NewInt(4),
+		N:     mustBigInt("29246302889428143187362802287225875743"),
+		Order: mustBigInt("233970423115425145498902418297807005944"),
+	}
+}
+
+// TwistOrder returns the number of points on the curve's quadratic
+// twist, 2P + 2 - Order.
+func (c *MontgomeryCurve) TwistOrder() *big.Int {
+	res := new(big.Int).Add(c.P, big1)
+	return res.Lsh(res, 1).Sub(res, c.Order)
+}
+
+// Ladder returns the u coordinate of kQ, where u is the u coordinate
+// of Q, with the Montgomery ladder. The identity maps to 0. It never
+// checks that u is on the curve, and works just as well for points
+// on the twist. Since u(-kQ) = u(kQ), a negative k is the same as -k.
+func (c *MontgomeryCurve) Ladder(u, k *big.Int) *big.Int {
+	var (
+		u2, w2 = big.NewInt(1), new(big.Int)
+		u3, w3 = new(big.Int).Set(u), big.NewInt(1)
+		t1, t2 = new(big.Int), new(big.Int)
+	)
+	k = new(big.Int).Abs(k)
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		b := k.Bit(i)
+		if b == 1 {
+			u2, u3 = u3, u2
+			w2, w3 = w3, w2
+		}
+
+		// (u3, w3) = ((u2*u3 - w2*w3)^2, u * (u2*w3 - w2*u3)^2)
+		nu3 := new(big.Int).Mul(u2, u3)
+		nu3.Sub(nu3, t1.Mul(w2, w3)).Mul(nu3, nu3).Mod(nu3, c.P)
+		nw3 := new(big.Int).Mul(u2, w3)
+		nw3.Sub(nw3, t1.Mul(w2, u3)).Mul(nw3, nw3).Mul(nw3, u).Mod(nw3, c.P)
+
+		// (u2, w2) = ((u2^2 - w2^2)^2, 4*u2*w2 * (u2^2 + A*u2*w2 + w2^2))
+		nu2 := new(big.Int).Mul(u2, u2)
+		nu2.Sub(nu2, t1.Mul(w2, w2)).Mul(nu2, nu2).Mod(nu2, c.P)
+		nw2 := new(big.Int).Mul(u2, u2)
+		nw2.Add(nw2, t1.Mul(c.A, u2).Mul(t1, w2)).Add(nw2, t1.Mul(w2, w2))
+		nw2.Mul(nw2, t2.Mul(u2, w2).Lsh(t2, 2)).Mod(nw2, c.P)
+
+		u2, w2, u3, w3 = nu2, nw2, nu3, nw3
+		if b == 1 {
+			u2, u3 = u3, u2
+			w2, w3 = w3, w2
+		}
+	}
+
+	if w2.Sign() == 0 {
+		return new(big.Int)
+	}
+	w2.ModInverse(w2, c.P)
+	return w2.Mul(w2, u2).Mod(w2, c.P)
+}
+
+// Weierstrass returns the equivalent short Weierstrass curve, with
+// a = (3 - A^2) / 3B^2 and b = (2A^3 - 9A) / 27B^3.
+func (c *MontgomeryCurve) Weierstrass() *Curve {
+	var (
+		a   = new(big.Int).Mul(c.A, c.A)
+		b   = new(big.Int).Mul(c.A, c.A)
+		den = new(big.Int).Mul(c.B, c.B)
+	)
+	a.Sub(big3, a).Mul(a, c.modInverse(den.Mul(den, big3)))
+	b.Mul(b, c.A).Lsh(b, 1).Sub(b, den.Mul(c.A, big.NewInt(9)))
+	den.Exp(c.B, big3, c.P).Mul(den, big.NewInt(27))
+	b.Mul(b, c.modInverse(den))
+
+	w := &Curve{
+		P:     new(big.Int).Set(c.P),
+		A:     a.Mod(a, c.P),
+		B:     b.Mod(b, c.P),
+		N:     new(big.Int).Set(c.N),
+		Order: new(big.Int).Set(c.Order),
+	}
+	v := new(big.Int).ModSqrt(c.rhs(c.U), c.P)
+	if v == nil {
+		panic("base point isn't on the curve")
+	}
+	w.G = c.ToWeierstrass(c.U, v)
+	return w
+}
+
+// ToWeierstrass maps (u, v) to the point (u/B + A/3B, v/B) on the
+// equivalent Weierstrass curve.
+func (c *MontgomeryCurve) ToWeierstrass(u, v *big.Int) *Point {
+	var (
+		binv = c.modInverse(c.B)
+		x    = new(big.Int).Mul(c.A, c.modInverse(big3))
+		y    = new(big.Int).Mul(v, binv)
+	)
+	x.Add(x, u).Mul(x, binv).Mod(x, c.P)
+	return &Point{X: x, Y: y.Mod(y, c.P)}
+}
+
+// FromWeierstrass maps a point on the equivalent Weierstrass curve
+// to (u, v). The identity maps to (0, 0), like in Ladder.
+func (c *MontgomeryCurve) FromWeierstrass(p *Point) (*big.Int, *big.Int) {
+	if p.IsIdentity() {
+		return new(big.Int), new(big.Int)
+	}
+	var (
+		u = new(big.Int).Mul(p.X, c.B)
+		v = new(big.Int).Mul(p.Y, c.B)
+	)
+	u.Sub(u, new(big.Int).Mul(c.A, c.modInverse(big3))).Mod(u, c.P)
+	return u, v.Mod(v, c.P)
+}
+
+// rhs returns (u^3 + Au^2 + u) / B mod P, which is v^2 if u is on
+// the curve.
+func (c *MontgomeryCurve) rhs(u *big.Int) *big.Int {
+	res := new(big.Int).Add(u, c.A)
+	res.Mul(res, u).Add(res, big1).Mul(res, u).Mul(res, c.modInverse(c.B))
+	return res.Mod(res, c.P)
+}
+
+// IsOnCurve reports whether u is the u coordinate of a point on the
+// curve, rather than its twist.
+func (c *MontgomeryCurve) IsOnCurve(u *big.Int) bool {
+	return big.Jacobi(c.rhs(u), c.P) >= 0
+}
+
+// modInverse returns x^-1 mod P.
+func (c *MontgomeryCurve) modInverse(x *big.Int) *big.Int {
+	res := new(big.Int).Mod(x, c.P)
+	return res.ModInverse(res, c.P)
+}
+
+// Bytes returns the fixed-length encoding of u.
+func (c *MontgomeryCurve) Bytes(u *big.Int) []byte {
+	return u.FillBytes(make([]byte, (c.P.BitLen()+7)/8))
+}
@@ -0,0 +1,144 @@
+package cryptopals
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func TestCurve(t *testing.T) {
+	t.Parallel()
+	c := Curve59()
+
+	if !c.IsOnCurve(c.G) {
+		t.Fatalf("base point %v isn't on the curve", c.G)
+	}
+	if p := c.ScalarBaseMult(c.N); !p.IsIdentity() {
+		t.Errorf("nG = %v, want identity", p)
+	}
+
+	var (
+		p = c.ScalarBaseMult(big.NewInt(12345))
+		q = c.ScalarBaseMult(big.NewInt(67890))
+		r = c.ScalarBaseMult(big.NewInt(13579))
+	)
+	if !c.Add(p, q).Equal(c.Add(q, p)) {
+		t.Error("addition isn't commutative")
+	}
+	if !c.Add(c.Add(p, q), r).Equal(c.Add(p, c.Add(q, r))) {
+		t.Error("addition isn't associative")
+	}
+	if !c.Add(p, Identity()).Equal(p) || !c.Add(Identity(), p).Equal(p) {
+		t.Error("identity isn't an identity")
+	}
+	if !c.Add(p, c.Neg(p)).IsIdentity() {
+		t.Error("p + -p isn't the identity")
+	}
+	if !c.Double(p).Equal(c.Add(p, p)) {
+		t.Error("2p != p + p")
+	}
+	for _, x := range []*Point{p, Identity()} {
+		if got, err := c.ParsePoint(c.Bytes(x)); err != nil || !got.Equal(x) {
+			t.Errorf("parsed %v as %v, %v", x, got, err)
+		}
+	}
+	if _, err := c.ParsePoint(c.Bytes(p)[1:]); err == nil {
+		t.Error("no error for a short point")
+	}
+
+	sum := Identity()
+	for i := int64(0); i < 20; i++ {
+		if got := c.ScalarMult(p, big.NewInt(i)); !got.Equal(sum) {
+			t.Errorf("%d*p = %v, want %v", i, got, sum)
+		}
+		if !c.IsOnCurve(sum) {
+			t.Errorf("%d*p isn't on the curve", i)
+		}
+		sum = c.Add(sum, p)
+	}
+	if got, want := c.ScalarBaseMult(big.NewInt(12345+67890)), c.Add(p, q); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCurve_PointOfOrder(t *testing.T) {
+	t.Parallel()
+	c := Challenge59InvalidCurves()[0]
+	r := big.NewInt(4999)
+
+	p, err := c.PointOfOrder(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsOnCurve(p) {
+		t.Errorf("%v isn't on the curve", p)
+	}
+	if q := c.ScalarMult(p, r); !q.IsIdentity() {
+		t.Errorf("rP = %v, want identity", q)
+	}
+	if Curve59().IsOnCurve(p) {
+		t.Errorf("%v is on the wrong curve", p)
+	}
+}
+
+func TestMontgomeryCurve(t *testing.T) {
+	t.Parallel()
+	var (
+		m = Curve60()
+		w = m.Weierstrass()
+		c = Curve59()
+	)
+
+	if w.A.Cmp(new(big.Int).Mod(c.A, c.P)) != 0 || w.B.Cmp(c.B) != 0 {
+		t.Errorf("got y^2 = x^3 + %vx + %v, want Curve59", w.A, w.B)
+	}
+	if !w.G.Equal(c.G) && !w.G.Equal(c.Neg(c.G)) {
+		t.Errorf("got base point %v, want %v", w.G, c.G)
+	}
+	if u := m.Ladder(m.U, m.N); u.Sign() != 0 {
+		t.Errorf("u(nG) = %v, want 0", u)
+	}
+
+	for _, k := range []int64{0, 1, 2, 3, 12345, 1 << 40} {
+		k := big.NewInt(k)
+		want, _ := m.FromWeierstrass(w.ScalarBaseMult(k))
+		if got := m.Ladder(m.U, k); got.Cmp(want) != 0 {
+			t.Errorf("u(%vG) = %v, want %v", k, got, want)
+		}
+	}
+
+	// Scalars longer than p, and negative ones, aren't truncated.
+	var (
+		k    = big.NewInt(12345)
+		long = new(big.Int).Lsh(m.N, 200)
+	)
+	long.Add(long, k)
+	want := m.Ladder(m.U, k)
+	for _, k := range []*big.Int{long, new(big.Int).Neg(k)} {
+		if got := m.Ladder(m.U, k); got.Cmp(want) != 0 {
+			t.Errorf("u(%vG) = %v, want %v", k, got, want)
+		}
+	}
+
+	p := w.ScalarBaseMult(big.NewInt(98765))
+	if u, v := m.FromWeierstrass(p); !m.ToWeierstrass(u, v).Equal(p) {
+		t.Errorf("%v didn't survive a round trip", p)
+	}
+}
+
+func TestGroupKangaroo(t *testing.T) {
+	t.Parallel()
+	var (
+		c = Curve59()
+		x = big.NewInt(1<<20 + 54321)
+		y = c.ScalarBaseMult(x)
+	)
+
+	got, err := GroupKangaroo(context.Background(), c.Group(), c.G, y, big.NewInt(1<<20), big.NewInt(1<<21), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(x) != 0 {
+		t.Errorf("got %v, want %v", got, x)
+	}
+}
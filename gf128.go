@@ -0,0 +1,455 @@
+package cryptopals
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// GF128 is an element of GF(2^128) = GF(2)[x]/(x^128 + x^7 + x^2 + x + 1)
+// in GCM's bit order. The most significant bit of the first byte is
+// the coefficient of 1, and the least significant bit of the last
+// byte is the coefficient of x^127.
+type GF128 struct {
+	hi, lo uint64
+}
+
+// GF128One is the multiplicative identity.
+var GF128One = GF128{hi: 1 << 63}
+
+// gf128R is x^128 reduced mod the GCM polynomial, shifted right once.
+const gf128R = 0xe1 << 56
+
+// GF128FromBytes returns the element encoded by a 16-byte block.
+func GF128FromBytes(b []byte) GF128 {
+	if len(b) != 16 {
+		panic(fmt.Sprintf("invalid GF128 length %d", len(b)))
+	}
+	return GF128{hi: binary.BigEndian.Uint64(b), lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+// Bytes returns the 16-byte encoding of a.
+func (a GF128) Bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, a.hi)
+	binary.BigEndian.PutUint64(b[8:], a.lo)
+	return b
+}
+
+// String returns a in hex.
+func (a GF128) String() string {
+	return fmt.Sprintf("%016x%016x", a.hi, a.lo)
+}
+
+// IsZero reports whether a is zero.
+func (a GF128) IsZero() bool {
+	return a.hi == 0 && a.lo == 0
+}
+
+// Bit returns the coefficient of x^i.
+func (a GF128) Bit(i int) uint {
+	if i < 64 {
+		return uint(a.hi>>(63-i)) & 1
+	}
+	return uint(a.lo>>(127-i)) & 1
+}
+
+// Add returns a + b, which is also a - b.
+func (a GF128) Add(b GF128) GF128 {
+	return GF128{hi: a.hi ^ b.hi, lo: a.lo ^ b.lo}
+}
+
+// mulX returns a*x.
+func (a GF128) mulX() GF128 {
+	carry := a.lo & 1
+	a.lo = a.lo>>1 | a.hi<<63
+	a.hi >>= 1
+	if carry == 1 {
+		a.hi ^= gf128R
+	}
+	return a
+}
+
+// Mul returns a*b, the same way as NIST SP 800-38D, Algorithm 1.
+func (a GF128) Mul(b GF128) GF128 {
+	var z GF128
+	v := b
+	for i := 0; i < 128; i++ {
+		if a.Bit(i) == 1 {
+			z = z.Add(v)
+		}
+		v = v.mulX()
+	}
+	return z
+}
+
+// Square returns a^2.
+func (a GF128) Square() GF128 {
+	return a.Mul(a)
+}
+
+// Exp returns a^k. The exponent k must be non-negative.
+func (a GF128) Exp(k *big.Int) GF128 {
+	res := GF128One
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		res = res.Square()
+		if k.Bit(i) == 1 {
+			res = res.Mul(a)
+		}
+	}
+	return res
+}
+
+// Sqrt returns the square root of a, which is a^(2^127).
+func (a GF128) Sqrt() GF128 {
+	for i := 0; i < 127; i++ {
+		a = a.Square()
+	}
+	return a
+}
+
+// gf128InvExp is 2^128 - 2.
+var gf128InvExp = new(big.Int).Sub(new(big.Int).Lsh(big1, 128), big2)
+
+// Inverse returns a^-1, or zero if a is zero.
+func (a GF128) Inverse() GF128 {
+	return a.Exp(gf128InvExp)
+}
+
+// randGF128 returns a random element.
+func randGF128() (GF128, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return GF128{}, err
+	}
+	return GF128FromBytes(b), nil
+}
+
+// GF128Poly is a polynomial with GF128 coefficients, in order of
+// increasing degree. The methods keep it trimmed, so the last
+// coefficient is never zero, and the zero polynomial is empty.
+type GF128Poly []GF128
+
+// GF128PolyFactor represents a factor P of a polynomial, along with
+// an integer K whose meaning depends on the factorization.
+type GF128PolyFactor struct {
+	P GF128Poly
+	K int
+}
+
+// NewGF128Poly returns the polynomial with the given coefficients, in
+// order of increasing degree.
+func NewGF128Poly(coeffs ...GF128) GF128Poly {
+	return GF128Poly(coeffs).trim()
+}
+
+// trim drops leading zero coefficients.
+func (p GF128Poly) trim() GF128Poly {
+	for len(p) > 0 && p[len(p)-1].IsZero() {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// Degree returns the degree of p, or -1 if p is zero.
+func (p GF128Poly) Degree() int {
+	return len(p) - 1
+}
+
+// IsOne reports whether p is 1.
+func (p GF128Poly) IsOne() bool {
+	return len(p) == 1 && p[0] == GF128One
+}
+
+// Equal reports whether p and q are equal.
+func (p GF128Poly) Equal(q GF128Poly) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	for i := range p {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns p as a sum of terms, highest degree first.
+func (p GF128Poly) String() string {
+	if len(p) == 0 {
+		return "0"
+	}
+	var b strings.Builder
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].IsZero() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(" + ")
+		}
+		fmt.Fprintf(&b, "%v*X^%d", p[i], i)
+	}
+	return b.String()
+}
+
+// Add returns p + q.
+func (p GF128Poly) Add(q GF128Poly) GF128Poly {
+	if len(p) < len(q) {
+		p, q = q, p
+	}
+	res := make(GF128Poly, len(p))
+	copy(res, p)
+	for i := range q {
+		res[i] = res[i].Add(q[i])
+	}
+	return res.trim()
+}
+
+// Mul returns p * q.
+func (p GF128Poly) Mul(q GF128Poly) GF128Poly {
+	if len(p) == 0 || len(q) == 0 {
+		return nil
+	}
+	res := make(GF128Poly, len(p)+len(q)-1)
+	for i := range p {
+		for j := range q {
+			res[i+j] = res[i+j].Add(p[i].Mul(q[j]))
+		}
+	}
+	return res.trim()
+}
+
+// Scale returns c * p.
+func (p GF128Poly) Scale(c GF128) GF128Poly {
+	res := make(GF128Poly, len(p))
+	for i := range p {
+		res[i] = p[i].Mul(c)
+	}
+	return res.trim()
+}
+
+// DivMod returns the quotient and remainder of p / q. It panics if q
+// is zero.
+func (p GF128Poly) DivMod(q GF128Poly) (GF128Poly, GF128Poly) {
+	if len(q) == 0 {
+		panic("division by zero polynomial")
+	}
+	if len(p) < len(q) {
+		return nil, append(GF128Poly(nil), p...)
+	}
+
+	var (
+		r   = append(GF128Poly(nil), p...)
+		quo = make(GF128Poly, len(p)-len(q)+1)
+		inv = q[len(q)-1].Inverse()
+	)
+	for i := len(quo) - 1; i >= 0; i-- {
+		c := r[i+len(q)-1].Mul(inv)
+		quo[i] = c
+		for j := range q {
+			r[i+j] = r[i+j].Add(c.Mul(q[j]))
+		}
+	}
+	return quo.trim(), r.trim()
+}
+
+// Mod returns p mod q.
+func (p GF128Poly) Mod(q GF128Poly) GF128Poly {
+	_, r := p.DivMod(q)
+	return r
+}
+
+// Monic returns p divided by its leading coefficient.
+func (p GF128Poly) Monic() GF128Poly {
+	if len(p) == 0 {
+		return nil
+	}
+	return p.Scale(p[len(p)-1].Inverse())
+}
+
+// GCD returns the monic greatest common divisor of p and q.
+func (p GF128Poly) GCD(q GF128Poly) GF128Poly {
+	for len(q) > 0 {
+		p, q = q, p.Mod(q)
+	}
+	return p.Monic()
+}
+
+// ExpMod returns p^k mod m. The exponent k must be non-negative.
+func (p GF128Poly) ExpMod(k *big.Int, m GF128Poly) GF128Poly {
+	var (
+		res  = NewGF128Poly(GF128One).Mod(m)
+		base = p.Mod(m)
+	)
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		res = res.Mul(res).Mod(m)
+		if k.Bit(i) == 1 {
+			res = res.Mul(base).Mod(m)
+		}
+	}
+	return res
+}
+
+// Eval returns p(x).
+func (p GF128Poly) Eval(x GF128) GF128 {
+	var res GF128
+	for i := len(p) - 1; i >= 0; i-- {
+		res = res.Mul(x).Add(p[i])
+	}
+	return res
+}
+
+// Derivative returns the formal derivative of p. In characteristic
+// 2, the even terms vanish.
+func (p GF128Poly) Derivative() GF128Poly {
+	if len(p) <= 1 {
+		return nil
+	}
+	res := make(GF128Poly, len(p)-1)
+	for i := 1; i < len(p); i += 2 {
+		res[i-1] = p[i]
+	}
+	return res.trim()
+}
+
+// sqrt returns the square root of p, whose derivative must be zero.
+func (p GF128Poly) sqrt() GF128Poly {
+	res := make(GF128Poly, len(p)/2+1)
+	for i := 0; i < len(p); i += 2 {
+		res[i/2] = p[i].Sqrt()
+	}
+	return res.trim()
+}
+
+// frobenius returns x^(2^128) mod m.
+func frobenius(x, m GF128Poly) GF128Poly {
+	for i := 0; i < 128; i++ {
+		x = x.Mul(x).Mod(m)
+	}
+	return x
+}
+
+// SquareFree returns the square-free factorization of the monic
+// polynomial p. Each P is square-free, and p is the product of the
+// P^K.
+func (p GF128Poly) SquareFree() []GF128PolyFactor {
+	var (
+		res  []GF128PolyFactor
+		c    = p.GCD(p.Derivative())
+		w, _ = p.DivMod(c)
+	)
+	for i := 1; !w.IsOne() && len(w) > 0; i++ {
+		y := w.GCD(c)
+		fac, _ := w.DivMod(y)
+		if !fac.IsOne() {
+			res = append(res, GF128PolyFactor{P: fac, K: i})
+		}
+		w = y
+		c, _ = c.DivMod(y)
+	}
+	if !c.IsOne() && len(c) > 0 {
+		// What's left is a square, since its derivative is zero.
+		for _, f := range c.sqrt().SquareFree() {
+			res = append(res, GF128PolyFactor{P: f.P, K: 2 * f.K})
+		}
+	}
+	return res
+}
+
+// DistinctDegree returns the distinct-degree factorization of the
+// monic square-free polynomial p. Each P is the product of all the
+// irreducible factors of p with degree K.
+func (p GF128Poly) DistinctDegree() []GF128PolyFactor {
+	var (
+		res []GF128PolyFactor
+		x   = NewGF128Poly(GF128{}, GF128One)
+		h   = x // x^(q^i) mod p
+	)
+	for i := 1; p.Degree() >= 2*i; i++ {
+		h = frobenius(h, p)
+		g := p.GCD(h.Add(x))
+		if !g.IsOne() {
+			res = append(res, GF128PolyFactor{P: g, K: i})
+			p, _ = p.DivMod(g)
+			h = h.Mod(p)
+		}
+	}
+	if p.Degree() > 0 {
+		res = append(res, GF128PolyFactor{P: p, K: p.Degree()})
+	}
+	return res
+}
+
+// EqualDegree splits the monic polynomial p, which must be a product
+// of distinct irreducible polynomials of degree d, into those
+// factors with the Cantor-Zassenhaus algorithm. Since q is even, it
+// splits with the trace h + h^2 + ... + h^(2^(128d-1)) of a random h
+// instead of h^((q^d-1)/2).
+func (p GF128Poly) EqualDegree(d int) ([]GF128Poly, error) {
+	if d <= 0 || p.Degree()%d != 0 {
+		return nil, fmt.Errorf("invalid degree %d", d)
+	}
+	var (
+		n   = p.Degree() / d
+		res = []GF128Poly{p}
+	)
+	for len(res) < n {
+		h := make(GF128Poly, p.Degree())
+		for i := range h {
+			var err error
+			if h[i], err = randGF128(); err != nil {
+				return nil, err
+			}
+		}
+		h = h.trim()
+
+		var (
+			t  = h
+			hi = h
+		)
+		for i := 1; i < 128*d; i++ {
+			hi = hi.Mul(hi).Mod(p)
+			t = t.Add(hi)
+		}
+
+		var next []GF128Poly
+		for _, u := range res {
+			if u.Degree() > d {
+				g := u.GCD(t.Mod(u))
+				if !g.IsOne() && g.Degree() < u.Degree() {
+					q, _ := u.DivMod(g)
+					next = append(next, g, q)
+					continue
+				}
+			}
+			next = append(next, u)
+		}
+		res = next
+	}
+	return res, nil
+}
+
+// Roots returns the distinct roots of p in GF(2^128).
+func (p GF128Poly) Roots() ([]GF128, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("zero polynomial")
+	}
+	var res []GF128
+	for _, sf := range p.Monic().SquareFree() {
+		for _, dd := range sf.P.DistinctDegree() {
+			if dd.K != 1 {
+				continue
+			}
+			linear, err := dd.P.EqualDegree(1)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range linear {
+				res = append(res, f[0]) // X + c has root c.
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,190 @@
+package cryptopals
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestGF128(t *testing.T) {
+	t.Parallel()
+	var (
+		a = helperRandGF128(t)
+		b = helperRandGF128(t)
+		c = helperRandGF128(t)
+	)
+
+	if got := a.Mul(GF128One); got != a {
+		t.Errorf("a*1 = %v, want %v", got, a)
+	}
+	if got := a.Add(a); !got.IsZero() {
+		t.Errorf("a+a = %v, want 0", got)
+	}
+	if a.Mul(b) != b.Mul(a) {
+		t.Error("multiplication isn't commutative")
+	}
+	if a.Mul(b).Mul(c) != a.Mul(b.Mul(c)) {
+		t.Error("multiplication isn't associative")
+	}
+	if a.Mul(b.Add(c)) != a.Mul(b).Add(a.Mul(c)) {
+		t.Error("multiplication doesn't distribute")
+	}
+	if got := a.Mul(a.Inverse()); got != GF128One {
+		t.Errorf("a*a^-1 = %v, want 1", got)
+	}
+	if got := a.Exp(big.NewInt(3)); got != a.Mul(a).Mul(a) {
+		t.Errorf("a^3 = %v, want %v", got, a.Mul(a).Mul(a))
+	}
+	if got := a.Exp(new(big.Int).Lsh(big1, 128)); got != a {
+		t.Errorf("a^(2^128) = %v, want %v", got, a)
+	}
+}
+
+func TestGF128_Mul(t *testing.T) {
+	t.Parallel()
+	// The bit order is reversed, so x * x^127 wraps around to
+	// x^7 + x^2 + x + 1, which is 0xe1 in the first byte.
+	var (
+		x    = GF128{hi: 1 << 62}
+		x2   = GF128{hi: 1 << 61}
+		x127 = GF128{lo: 1}
+		want = GF128FromBytes(HelperDecodeHex(t, "e1000000000000000000000000000000"))
+	)
+	if got := x.Mul(x); got != x2 {
+		t.Errorf("x*x = %v, want %v", got, x2)
+	}
+	if got := x.Mul(x127); got != want {
+		t.Errorf("x*x^127 = %v, want %v", got, want)
+	}
+}
+
+func helperRandGF128(tb testing.TB) GF128 {
+	tb.Helper()
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		tb.Fatal(err)
+	}
+	return GF128FromBytes(b)
+}
+
+func TestGF128Poly(t *testing.T) {
+	t.Parallel()
+	var (
+		p = helperRandGF128Poly(t, 7)
+		q = helperRandGF128Poly(t, 3)
+		r = helperRandGF128Poly(t, 2)
+	)
+
+	quo, rem := p.DivMod(q)
+	if rem.Degree() >= q.Degree() {
+		t.Errorf("remainder %v has degree %d", rem, rem.Degree())
+	}
+	if got := quo.Mul(q).Add(rem); !got.Equal(p) {
+		t.Errorf("q*quo + rem = %v, want %v", got, p)
+	}
+
+	g := p.Mul(r).GCD(q.Mul(r))
+	if want := r.Monic(); g.Degree() < want.Degree() || len(g.Mod(want)) != 0 {
+		t.Errorf("gcd %v isn't a multiple of %v", g, want)
+	}
+
+	x := helperRandGF128(t)
+	if got, want := p.Mul(q).Eval(x), p.Eval(x).Mul(q.Eval(x)); got != want {
+		t.Errorf("(pq)(x) = %v, want %v", got, want)
+	}
+	if got, want := p.ExpMod(big.NewInt(5), q), p.Mul(p).Mul(p).Mul(p).Mul(p).Mod(q); !got.Equal(want) {
+		t.Errorf("p^5 mod q = %v, want %v", got, want)
+	}
+	if m := p.Monic(); m[m.Degree()] != GF128One {
+		t.Errorf("monic polynomial %v has leading coefficient %v", m, m[m.Degree()])
+	}
+}
+
+func TestGF128Poly_SquareFree(t *testing.T) {
+	t.Parallel()
+	var (
+		a = NewGF128Poly(helperRandGF128(t), GF128One)
+		b = NewGF128Poly(helperRandGF128(t), GF128One)
+		c = NewGF128Poly(helperRandGF128(t), GF128One)
+		d = NewGF128Poly(helperRandGF128(t), GF128One)
+	)
+	// a * b^2 * c^3 * d^4
+	p := a.Mul(b).Mul(b).Mul(c).Mul(c).Mul(c).Mul(d).Mul(d).Mul(d).Mul(d)
+
+	got := p.SquareFree()
+	want := []GF128PolyFactor{{a, 1}, {b, 2}, {c, 3}, {d, 4}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d factors, want %d", len(got), len(want))
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if g.P.Equal(w.P) && g.K == w.K {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing factor (%v)^%d", w.P, w.K)
+		}
+	}
+}
+
+func TestGF128Poly_Factor(t *testing.T) {
+	t.Parallel()
+	// Find two irreducible quadratics.
+	var quads []GF128Poly
+	for len(quads) < 2 {
+		q := NewGF128Poly(helperRandGF128(t), helperRandGF128(t), GF128One)
+		if dd := q.DistinctDegree(); len(dd) == 1 && dd[0].K == 2 {
+			quads = append(quads, q)
+		}
+	}
+	var (
+		lin1 = NewGF128Poly(helperRandGF128(t), GF128One)
+		lin2 = NewGF128Poly(helperRandGF128(t), GF128One)
+		p    = lin1.Mul(lin2).Mul(quads[0]).Mul(quads[1])
+	)
+
+	dd := p.DistinctDegree()
+	if len(dd) != 2 || dd[0].K != 1 || dd[1].K != 2 {
+		t.Fatalf("got distinct-degree factors %v", dd)
+	}
+	if !dd[0].P.Equal(lin1.Mul(lin2)) || !dd[1].P.Equal(quads[0].Mul(quads[1])) {
+		t.Errorf("got distinct-degree factors %v", dd)
+	}
+
+	for _, f := range dd {
+		split, err := f.P.EqualDegree(f.K)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(split) != 2 {
+			t.Fatalf("got %d degree-%d factors, want 2", len(split), f.K)
+		}
+		if !split[0].Mul(split[1]).Equal(f.P) {
+			t.Errorf("factors %v don't multiply to %v", split, f.P)
+		}
+	}
+
+	roots, err := p.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 {
+		t.Fatalf("got %d roots, want 2", len(roots))
+	}
+	for _, r := range roots {
+		if !p.Eval(r).IsZero() {
+			t.Errorf("%v isn't a root", r)
+		}
+	}
+}
+
+func helperRandGF128Poly(tb testing.TB, degree int) GF128Poly {
+	tb.Helper()
+	p := make(GF128Poly, degree+1)
+	for i := range p {
+		p[i] = helperRandGF128(tb)
+	}
+	return p.trim()
+}
@@ -0,0 +1,301 @@
+package cryptopals
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// BitMatrix is a matrix over GF(2). Each row is packed into words,
+// least significant bit first.
+type BitMatrix struct {
+	rows, cols int
+	data       [][]uint64
+}
+
+// NewBitMatrix returns a zero matrix.
+func NewBitMatrix(rows, cols int) *BitMatrix {
+	m := &BitMatrix{rows: rows, cols: cols, data: make([][]uint64, rows)}
+	for i := range m.data {
+		m.data[i] = make([]uint64, (cols+63)/64)
+	}
+	return m
+}
+
+// IdentityBitMatrix returns the n×n identity matrix.
+func IdentityBitMatrix(n int) *BitMatrix {
+	m := NewBitMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.Set(i, i, 1)
+	}
+	return m
+}
+
+// Rows returns the number of rows.
+func (m *BitMatrix) Rows() int {
+	return m.rows
+}
+
+// Cols returns the number of columns.
+func (m *BitMatrix) Cols() int {
+	return m.cols
+}
+
+// Get returns the entry at row i and column j.
+func (m *BitMatrix) Get(i, j int) uint {
+	return uint(m.data[i][j/64]>>(j%64)) & 1
+}
+
+// Set sets the entry at row i and column j to the low bit of v.
+func (m *BitMatrix) Set(i, j int, v uint) {
+	if v&1 == 1 {
+		m.data[i][j/64] |= 1 << (j % 64)
+	} else {
+		m.data[i][j/64] &^= 1 << (j % 64)
+	}
+}
+
+// Clone returns a copy of m.
+func (m *BitMatrix) Clone() *BitMatrix {
+	res := NewBitMatrix(m.rows, m.cols)
+	for i := range m.data {
+		copy(res.data[i], m.data[i])
+	}
+	return res
+}
+
+// Equal reports whether m and n are equal.
+func (m *BitMatrix) Equal(n *BitMatrix) bool {
+	if m.rows != n.rows || m.cols != n.cols {
+		return false
+	}
+	for i := range m.data {
+		for j := range m.data[i] {
+			if m.data[i][j] != n.data[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// String returns m as rows of 0s and 1s.
+func (m *BitMatrix) String() string {
+	b := make([]byte, 0, m.rows*(m.cols+1))
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			b = append(b, '0'+byte(m.Get(i, j)))
+		}
+		b = append(b, '\n')
+	}
+	return string(b)
+}
+
+// Mul returns m*n. It panics if the sizes don't match.
+func (m *BitMatrix) Mul(n *BitMatrix) *BitMatrix {
+	if m.cols != n.rows {
+		panic(fmt.Sprintf("can't multiply %dx%d by %dx%d", m.rows, m.cols, n.rows, n.cols))
+	}
+	res := NewBitMatrix(m.rows, n.cols)
+	for i := 0; i < m.rows; i++ {
+		// Row i of the result is the sum of the rows of n picked out
+		// by row i of m.
+		for w, word := range m.data[i] {
+			for word != 0 {
+				k := w*64 + bits.TrailingZeros64(word)
+				word &= word - 1
+				xorWords(res.data[i], n.data[k])
+			}
+		}
+	}
+	return res
+}
+
+// Add returns m + n. It panics if the sizes don't match.
+func (m *BitMatrix) Add(n *BitMatrix) *BitMatrix {
+	if m.rows != n.rows || m.cols != n.cols {
+		panic(fmt.Sprintf("can't add %dx%d to %dx%d", m.rows, m.cols, n.rows, n.cols))
+	}
+	res := m.Clone()
+	for i := range res.data {
+		xorWords(res.data[i], n.data[i])
+	}
+	return res
+}
+
+// Stack returns m with the rows of n appended. It panics if the
+// numbers of columns don't match.
+func (m *BitMatrix) Stack(n *BitMatrix) *BitMatrix {
+	if m.cols != n.cols {
+		panic(fmt.Sprintf("can't stack %dx%d on %dx%d", n.rows, n.cols, m.rows, m.cols))
+	}
+	res := NewBitMatrix(m.rows+n.rows, m.cols)
+	for i := range m.data {
+		copy(res.data[i], m.data[i])
+	}
+	for i := range n.data {
+		copy(res.data[m.rows+i], n.data[i])
+	}
+	return res
+}
+
+// Slice returns rows i up to j of m.
+func (m *BitMatrix) Slice(i, j int) *BitMatrix {
+	res := NewBitMatrix(j-i, m.cols)
+	for k := range res.data {
+		copy(res.data[k], m.data[i+k])
+	}
+	return res
+}
+
+// Transpose returns the transpose of m.
+func (m *BitMatrix) Transpose() *BitMatrix {
+	res := NewBitMatrix(m.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			if m.Get(i, j) == 1 {
+				res.Set(j, i, 1)
+			}
+		}
+	}
+	return res
+}
+
+// RowReduce returns the reduced row echelon form of m, and the
+// columns of its pivots. The number of pivots is the rank.
+func (m *BitMatrix) RowReduce() (*BitMatrix, []int) {
+	var (
+		res    = m.Clone()
+		pivots []int
+		r      = 0
+	)
+	for j := 0; j < res.cols && r < res.rows; j++ {
+		p := -1
+		for i := r; i < res.rows; i++ {
+			if res.Get(i, j) == 1 {
+				p = i
+				break
+			}
+		}
+		if p < 0 {
+			continue
+		}
+		res.data[r], res.data[p] = res.data[p], res.data[r]
+		for i := 0; i < res.rows; i++ {
+			if i != r && res.Get(i, j) == 1 {
+				xorWords(res.data[i], res.data[r])
+			}
+		}
+		pivots = append(pivots, j)
+		r++
+	}
+	return res, pivots
+}
+
+// Rank returns the rank of m.
+func (m *BitMatrix) Rank() int {
+	_, pivots := m.RowReduce()
+	return len(pivots)
+}
+
+// Kernel returns a basis for the null space of m, the vectors v with
+// mv = 0, as the rows of a matrix.
+func (m *BitMatrix) Kernel() *BitMatrix {
+	rref, pivots := m.RowReduce()
+	var (
+		isPivot = make([]bool, m.cols)
+		free    []int
+	)
+	for _, j := range pivots {
+		isPivot[j] = true
+	}
+	for j := 0; j < m.cols; j++ {
+		if !isPivot[j] {
+			free = append(free, j)
+		}
+	}
+
+	// Each free column gives one basis vector: set it, and solve for
+	// the pivot columns.
+	res := NewBitMatrix(len(free), m.cols)
+	for k, f := range free {
+		res.Set(k, f, 1)
+		for r, p := range pivots {
+			if rref.Get(r, f) == 1 {
+				res.Set(k, p, 1)
+			}
+		}
+	}
+	return res
+}
+
+// xorWords sets dst ^= src.
+func xorWords(dst, src []uint64) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// gf128Vector returns a as a 128×1 column vector.
+func gf128Vector(a GF128) *BitMatrix {
+	v := NewBitMatrix(128, 1)
+	for i := 0; i < 128; i++ {
+		v.Set(i, 0, a.Bit(i))
+	}
+	return v
+}
+
+// GF128FromVector returns the element whose coefficients are the
+// entries of the 128×1 column vector v.
+func GF128FromVector(v *BitMatrix) GF128 {
+	return gf128Column(v, 0)
+}
+
+// gf128Column returns the element whose coefficients are the entries
+// of column j of the 128-row matrix m.
+func gf128Column(m *BitMatrix, j int) GF128 {
+	var a GF128
+	for i := 0; i < 128; i++ {
+		if m.Get(i, j) == 1 {
+			a = a.Add(gf128Monomial(i))
+		}
+	}
+	return a
+}
+
+// gf128Monomial returns x^i, for i in [0, 128).
+func gf128Monomial(i int) GF128 {
+	if i < 64 {
+		return GF128{hi: 1 << (63 - i)}
+	}
+	return GF128{lo: 1 << (127 - i)}
+}
+
+// GF128MulMatrix returns the 128×128 matrix M with M*v(a) = v(c*a),
+// where v maps an element to the column vector of its coefficients.
+func GF128MulMatrix(c GF128) *BitMatrix {
+	m := NewBitMatrix(128, 128)
+	for j := 0; j < 128; j++ {
+		col := c.Mul(gf128Monomial(j))
+		for i := 0; i < 128; i++ {
+			if col.Bit(i) == 1 {
+				m.Set(i, j, 1)
+			}
+		}
+	}
+	return m
+}
+
+// GF128SquareMatrix returns the 128×128 matrix S with S*v(a) = v(a^2).
+// Squaring is linear in characteristic 2.
+func GF128SquareMatrix() *BitMatrix {
+	m := NewBitMatrix(128, 128)
+	for j := 0; j < 128; j++ {
+		col := gf128Monomial(j).Square()
+		for i := 0; i < 128; i++ {
+			if col.Bit(i) == 1 {
+				m.Set(i, j, 1)
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,103 @@
+package cryptopals
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestBitMatrix(t *testing.T) {
+	t.Parallel()
+	var (
+		a = helperRandBitMatrix(t, 5, 70)
+		b = helperRandBitMatrix(t, 70, 3)
+		c = helperRandBitMatrix(t, 3, 9)
+	)
+
+	if got := a.Mul(IdentityBitMatrix(70)); !got.Equal(a) {
+		t.Errorf("a*I = \n%v, want \n%v", got, a)
+	}
+	if !a.Mul(b).Mul(c).Equal(a.Mul(b.Mul(c))) {
+		t.Error("multiplication isn't associative")
+	}
+	if !a.Mul(b).Transpose().Equal(b.Transpose().Mul(a.Transpose())) {
+		t.Error("(ab)^T != b^T a^T")
+	}
+	if got := a.Add(a); !got.Equal(NewBitMatrix(5, 70)) {
+		t.Errorf("a+a = \n%v, want 0", got)
+	}
+	if got := a.Stack(a).Slice(5, 10); !got.Equal(a) {
+		t.Errorf("got \n%v, want \n%v", got, a)
+	}
+}
+
+func TestBitMatrix_Kernel(t *testing.T) {
+	t.Parallel()
+	// Three independent rows and one that's the sum of the first two.
+	a := helperRandBitMatrix(t, 4, 100)
+	a.data[3] = append([]uint64(nil), a.data[0]...)
+	xorWords(a.data[3], a.data[1])
+
+	rank := a.Rank()
+	if rank > 3 {
+		t.Fatalf("got rank %d, want at most 3", rank)
+	}
+	k := a.Kernel()
+	if k.Rows() != 100-rank {
+		t.Errorf("got %d kernel vectors, want %d", k.Rows(), 100-rank)
+	}
+	if k.Rank() != k.Rows() {
+		t.Error("kernel vectors aren't independent")
+	}
+	if got := a.Mul(k.Transpose()); !got.Equal(NewBitMatrix(4, k.Rows())) {
+		t.Errorf("a*k^T = \n%v, want 0", got)
+	}
+
+	rref, pivots := a.RowReduce()
+	for r, j := range pivots {
+		for i := 0; i < rref.Rows(); i++ {
+			if want := uint(0); i == r {
+				want = 1
+				if rref.Get(i, j) != want {
+					t.Errorf("pivot (%d, %d) isn't 1", i, j)
+				}
+			} else if rref.Get(i, j) != want {
+				t.Errorf("entry (%d, %d) above or below a pivot isn't 0", i, j)
+			}
+		}
+	}
+}
+
+func TestGF128MulMatrix(t *testing.T) {
+	t.Parallel()
+	var (
+		a = helperRandGF128(t)
+		c = helperRandGF128(t)
+		s = GF128SquareMatrix()
+	)
+	if got := GF128FromVector(GF128MulMatrix(c).Mul(gf128Vector(a))); got != c.Mul(a) {
+		t.Errorf("Mc*a = %v, want %v", got, c.Mul(a))
+	}
+	if got := GF128FromVector(s.Mul(gf128Vector(a))); got != a.Square() {
+		t.Errorf("Ms*a = %v, want %v", got, a.Square())
+	}
+	if got := GF128MulMatrix(c).Mul(GF128MulMatrix(a)); !got.Equal(GF128MulMatrix(c.Mul(a))) {
+		t.Error("Mc*Ma != M(c*a)")
+	}
+}
+
+func helperRandBitMatrix(tb testing.TB, rows, cols int) *BitMatrix {
+	tb.Helper()
+	var (
+		m = NewBitMatrix(rows, cols)
+		b = make([]byte, rows*cols)
+	)
+	if _, err := rand.Read(b); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			m.Set(i, j, uint(b[i*cols+j]))
+		}
+	}
+	return m
+}
@@ -0,0 +1,484 @@
+package cryptopals
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Factor represents a prime power P^K.
+type Factor struct {
+	P *big.Int
+	K int
+}
+
+// TrialDivision factors n by dividing out every prime up to bound.
+// It returns the prime factors it found in increasing order, and
+// the cofactor left over, which has no prime factors up to bound.
+func TrialDivision(n *big.Int, bound uint64) ([]Factor, *big.Int) {
+	var (
+		res []Factor
+		rem = new(big.Int).Abs(n)
+		d   = new(big.Int)
+		q   = new(big.Int)
+		m   = new(big.Int)
+	)
+
+	for p := uint64(2); p <= bound && rem.Cmp(big1) > 0; p++ {
+		if p > 2 && p%2 == 0 { // Composites never divide what's left, and evens are easy to skip.
+			continue
+		}
+		d.SetUint64(p)
+
+		var k int
+		for {
+			q.DivMod(rem, d, m)
+			if m.Sign() != 0 {
+				break
+			}
+			rem.Set(q)
+			k++
+		}
+		if k > 0 {
+			res = append(res, Factor{P: new(big.Int).Set(d), K: k})
+		}
+	}
+
+	return res, rem
+}
+
+// CRT solves the system x = residues[i] (mod moduli[i]) with the
+// Chinese remainder theorem. The moduli must be pairwise coprime.
+// It returns the smallest non-negative solution and the product
+// of the moduli.
+func CRT(residues, moduli []*big.Int) (*big.Int, *big.Int, error) {
+	if len(residues) != len(moduli) {
+		return nil, nil, fmt.Errorf("unequal lengths")
+	}
+
+	var (
+		x   = new(big.Int)
+		n   = big.NewInt(1)
+		tmp = new(big.Int)
+	)
+
+	for i, m := range moduli {
+		if m.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("invalid modulus %v", m)
+		}
+
+		// Find x' = x + n*t so that x' = residues[i] (mod m).
+		inv := new(big.Int).ModInverse(tmp.Mod(n, m), m)
+		if inv == nil && m.Cmp(big1) != 0 {
+			return nil, nil, fmt.Errorf("moduli not coprime")
+		}
+		if inv == nil { // Anything is 0 mod 1.
+			continue
+		}
+
+		t := new(big.Int).Sub(residues[i], x)
+		t.Mul(t, inv).Mod(t, m)
+		x.Add(x, tmp.Mul(n, t))
+		n.Mul(n, m)
+	}
+
+	return x.Mod(x, n), n, nil
+}
+
+// GroupElement is an element of a Group.
+type GroupElement interface{}
+
+// Group is a finite group, written multiplicatively, for generic
+// discrete log algorithms.
+type Group interface {
+	// Mul returns x*y.
+	Mul(x, y GroupElement) GroupElement
+	// Exp returns x^k. The exponent k must be non-negative.
+	Exp(x GroupElement, k *big.Int) GroupElement
+	// Equal reports whether x and y are the same element.
+	Equal(x, y GroupElement) bool
+	// Int returns an integer derived from x, for jump functions.
+	Int(x GroupElement) *big.Int
+}
+
+// ModPGroup is the multiplicative group of integers mod P.
+// Its elements are *big.Int.
+type ModPGroup struct {
+	P *big.Int
+}
+
+// Mul returns x*y mod P.
+func (g ModPGroup) Mul(x, y GroupElement) GroupElement {
+	res := new(big.Int).Mul(x.(*big.Int), y.(*big.Int))
+	return res.Mod(res, g.P)
+}
+
+// Exp returns x^k mod P.
+func (g ModPGroup) Exp(x GroupElement, k *big.Int) GroupElement {
+	return new(big.Int).Exp(x.(*big.Int), k, g.P)
+}
+
+// Equal reports whether x and y are equal.
+func (g ModPGroup) Equal(x, y GroupElement) bool {
+	return x.(*big.Int).Cmp(y.(*big.Int)) == 0
+}
+
+// Int returns x.
+func (g ModPGroup) Int(x GroupElement) *big.Int {
+	return x.(*big.Int)
+}
+
+// PohligHellman solves y = g^x in grp, where g has order
+// n = P1^K1 * P2^K2 * ..., for the given factors. It finds x mod
+// each Pi^Ki one digit at a time, with baby-step giant-step in the
+// subgroup of order Pi, so the Pi must be small.
+func PohligHellman(grp Group, g, y GroupElement, factors []Factor) (*big.Int, error) {
+	n := big.NewInt(1)
+	for _, f := range factors {
+		for i := 0; i < f.K; i++ {
+			n.Mul(n, f.P)
+		}
+	}
+
+	var (
+		residues []*big.Int
+		moduli   []*big.Int
+	)
+	for _, f := range factors {
+		var (
+			gamma = grp.Exp(g, new(big.Int).Div(n, f.P)) // Order f.P.
+			x     = new(big.Int)
+			pj    = big.NewInt(1) // f.P^j
+			e     = new(big.Int).Div(n, f.P)
+		)
+		for j := 0; j < f.K; j++ {
+			// h = (g^-x * y)^(n / p^(j+1)) has order f.P, and its log
+			// base gamma is the jth digit of x.
+			h := grp.Exp(g, new(big.Int).Sub(n, x))
+			h = grp.Exp(grp.Mul(h, y), e)
+
+			d, err := babyStepGiantStep(grp, gamma, h, f.P)
+			if err != nil {
+				return nil, err
+			}
+			x.Add(x, d.Mul(d, pj))
+			pj.Mul(pj, f.P)
+			e.Div(e, f.P)
+		}
+		residues = append(residues, x)
+		moduli = append(moduli, pj)
+	}
+
+	x, _, err := CRT(residues, moduli)
+	return x, err
+}
+
+// babyStepGiantStep finds x in [0, r) such that y = g^x, where g
+// has order r.
+func babyStepGiantStep(grp Group, g, y GroupElement, r *big.Int) (*big.Int, error) {
+	m := new(big.Int).Sqrt(r)
+	m.Add(m, big1)
+
+	// Group.Int needn't be unique, so keep every j for each key.
+	var (
+		table = make(map[string][]int64)
+		acc   = grp.Exp(g, new(big.Int))
+	)
+	for j := int64(0); j < m.Int64(); j++ {
+		key := grp.Int(acc).String()
+		table[key] = append(table[key], j)
+		acc = grp.Mul(acc, g)
+	}
+
+	var (
+		step = grp.Exp(g, new(big.Int).Sub(r, new(big.Int).Mod(m, r))) // g^-m
+		x    = new(big.Int)
+	)
+	acc = y
+	for i := new(big.Int); i.Cmp(m) <= 0; i.Add(i, big1) {
+		for _, j := range table[grp.Int(acc).String()] {
+			x.Mul(i, m).Add(x, big.NewInt(j)).Mod(x, r)
+			if grp.Equal(grp.Exp(g, x), y) {
+				return x, nil
+			}
+		}
+		acc = grp.Mul(acc, step)
+	}
+	return nil, fmt.Errorf("no log found mod %v", r)
+}
+
+// KangarooJump maps a group element to the index of a jump in
+// [0, k). The jump with index i has size 2^i. It gets the element
+// as returned by Group.Int.
+type KangarooJump func(y *big.Int, k int) int
+
+// DefaultKangarooJump maps y to its low 64 bits mod k.
+func DefaultKangarooJump(y *big.Int, k int) int {
+	return int(y.Uint64() % uint64(k))
+}
+
+// Kangaroo solves y = g^x mod p for x in [a, b] with Pollard's
+// kangaroo algorithm. See GroupKangaroo.
+func Kangaroo(ctx context.Context, g, y, p, a, b *big.Int, jump KangarooJump) (*big.Int, error) {
+	return GroupKangaroo(ctx, ModPGroup{P: p}, g, y, a, b, jump)
+}
+
+// GroupKangaroo solves y = g^x in grp for x in [a, b] with Pollard's
+// kangaroo algorithm. If jump is nil, DefaultKangarooJump is used.
+// The jump sizes are chosen so their mean is about half the square
+// root of the interval. If the kangaroos don't meet, it tries again
+// from a few random offsets before giving up. It checks ctx every
+// so often, and returns early if it's done.
+func GroupKangaroo(ctx context.Context, grp Group, g, y GroupElement, a, b *big.Int, jump KangarooJump) (*big.Int, error) {
+	width := new(big.Int).Sub(b, a)
+	if width.Sign() < 0 {
+		return nil, fmt.Errorf("invalid interval")
+	}
+	if jump == nil {
+		jump = DefaultKangarooJump
+	}
+
+	// Pick k so that the mean jump (2^k - 1)/k is about sqrt(b-a)/2.
+	var (
+		target = new(big.Int).Sqrt(width)
+		k      = 1
+		mean   = big.NewInt(1)
+		tmp    = new(big.Int)
+	)
+	target.Rsh(target, 1)
+	for mean.Cmp(target) < 0 {
+		k++
+		mean.Lsh(big1, uint(k)).Sub(mean, big1).Div(mean, tmp.SetInt64(int64(k)))
+	}
+
+	var (
+		sizes = make([]*big.Int, k)
+		jumps = make([]GroupElement, k)
+	)
+	for i := range sizes {
+		sizes[i] = new(big.Int).Lsh(big1, uint(i))
+		jumps[i] = grp.Exp(g, sizes[i])
+	}
+	n := new(big.Int).Mul(mean, big.NewInt(4))
+
+	const attempts = 4
+	for i := 0; i < attempts; i++ {
+		// Shift the whole problem by a random offset s after the
+		// first attempt, so the kangaroos take different paths.
+		s := new(big.Int)
+		if i > 0 {
+			var err error
+			s, err = rand.Int(rand.Reader, tmp.Add(width, big1))
+			if err != nil {
+				return nil, err
+			}
+		}
+		ys := grp.Mul(grp.Exp(g, s), y)
+
+		x, err := kangaroo(ctx, grp, g, ys, new(big.Int).Add(b, s), width, n, k, sizes, jumps, jump)
+		if err != nil {
+			return nil, err
+		}
+		if x != nil {
+			return x.Sub(x, s), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no solution found after %d attempts", attempts)
+}
+
+// kangaroo runs one tame and one wild kangaroo for y = g^x with x
+// in [b-width, b]. The tame kangaroo makes n jumps. It returns nil
+// if the kangaroos don't meet.
+func kangaroo(ctx context.Context, grp Group, g, y GroupElement, b, width, n *big.Int, k int, sizes []*big.Int, jumps []GroupElement, jump KangarooJump) (*big.Int, error) {
+	// The tame kangaroo starts at g^b and sets a trap.
+	var (
+		xT = new(big.Int)
+		yT = grp.Exp(g, b)
+		i  = new(big.Int)
+	)
+	for steps := 0; i.Cmp(n) < 0; i.Add(i, big1) {
+		if steps++; steps%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		j := jump(grp.Int(yT), k)
+		xT.Add(xT, sizes[j])
+		yT = grp.Mul(yT, jumps[j])
+	}
+
+	// The wild kangaroo starts at y, and either lands in the trap
+	// or passes it.
+	var (
+		xW    = new(big.Int)
+		yW    = y
+		limit = new(big.Int).Add(width, xT)
+	)
+	for steps := 0; xW.Cmp(limit) <= 0; {
+		if steps++; steps%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if grp.Equal(yW, yT) {
+			return xT.Add(xT, b).Sub(xT, xW), nil
+		}
+		j := jump(grp.Int(yW), k)
+		xW.Add(xW, sizes[j])
+		yW = grp.Mul(yW, jumps[j])
+	}
+
+	return nil, nil
+}
+
+// LLL returns an LLL-reduced basis for the lattice spanned by the
+// rows of basis, which must be linearly independent. The parameter
+// delta must be in (1/4, 1]. Larger values give shorter vectors, but
+// take longer. It leaves basis alone.
+func LLL(basis [][]*big.Rat, delta *big.Rat) ([][]*big.Rat, error) {
+	if delta.Cmp(big.NewRat(1, 4)) <= 0 || delta.Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("invalid delta %v", delta)
+	}
+	n := len(basis)
+	if n == 0 {
+		return nil, nil
+	}
+
+	b := make([][]*big.Rat, n)
+	for i, v := range basis {
+		if len(v) != len(basis[0]) {
+			return nil, fmt.Errorf("unequal lengths")
+		}
+		b[i] = make([]*big.Rat, len(v))
+		for j, x := range v {
+			b[i][j] = new(big.Rat).Set(x)
+		}
+	}
+
+	// mu[i][j] are the Gram-Schmidt coefficients, and bb[i] are the
+	// squared lengths of the Gram-Schmidt vectors. Rather than keep
+	// the vectors around, it updates both in place.
+	var (
+		mu   = make([][]*big.Rat, n)
+		bb   = make([]*big.Rat, n)
+		half = big.NewRat(1, 2)
+		tmp  = new(big.Rat)
+	)
+	for i := range mu {
+		mu[i] = make([]*big.Rat, n)
+		for j := range mu[i] {
+			mu[i][j] = new(big.Rat)
+		}
+		bb[i] = new(big.Rat)
+	}
+
+	// gramSchmidt fills in mu[k] and bb[k] from the vectors before it.
+	gramSchmidt := func(k int) error {
+		for j := 0; j < k; j++ {
+			m := ratDot(b[k], b[j])
+			for i := 0; i < j; i++ {
+				m.Sub(m, tmp.Mul(mu[j][i], mu[k][i]).Mul(tmp, bb[i]))
+			}
+			mu[k][j].Quo(m, bb[j])
+		}
+		bb[k] = ratDot(b[k], b[k])
+		for j := 0; j < k; j++ {
+			bb[k].Sub(bb[k], tmp.Mul(mu[k][j], mu[k][j]).Mul(tmp, bb[j]))
+		}
+		if bb[k].Sign() == 0 {
+			return fmt.Errorf("basis vectors not independent")
+		}
+		return nil
+	}
+
+	// reduce makes |mu[k][l]| <= 1/2 by subtracting a multiple of b[l]
+	// from b[k].
+	reduce := func(k, l int) {
+		if tmp.Abs(mu[k][l]).Cmp(half) <= 0 {
+			return
+		}
+		q := new(big.Rat).SetInt(ratRound(mu[k][l]))
+		for i := range b[k] {
+			b[k][i].Sub(b[k][i], tmp.Mul(q, b[l][i]))
+		}
+		mu[k][l].Sub(mu[k][l], q)
+		for i := 0; i < l; i++ {
+			mu[k][i].Sub(mu[k][i], tmp.Mul(q, mu[l][i]))
+		}
+	}
+
+	// swap exchanges b[k] and b[k-1], and updates mu and bb to match.
+	swap := func(k, kmax int) {
+		b[k], b[k-1] = b[k-1], b[k]
+		for j := 0; j < k-1; j++ {
+			mu[k][j], mu[k-1][j] = mu[k-1][j], mu[k][j]
+		}
+
+		m := new(big.Rat).Set(mu[k][k-1])
+		bNew := new(big.Rat).Mul(m, m)
+		bNew.Mul(bNew, bb[k-1]).Add(bNew, bb[k])
+		mu[k][k-1].Mul(m, bb[k-1]).Quo(mu[k][k-1], bNew)
+		bb[k].Mul(bb[k], bb[k-1]).Quo(bb[k], bNew)
+		bb[k-1] = bNew
+
+		for i := k + 1; i <= kmax; i++ {
+			t := new(big.Rat).Set(mu[i][k])
+			mu[i][k].Sub(mu[i][k-1], tmp.Mul(m, t))
+			mu[i][k-1].Add(t, tmp.Mul(mu[k][k-1], mu[i][k]))
+		}
+	}
+
+	if err := gramSchmidt(0); err != nil {
+		return nil, err
+	}
+	lovasz := new(big.Rat)
+	for k, kmax := 1, 0; k < n; {
+		if k > kmax {
+			kmax = k
+			if err := gramSchmidt(k); err != nil {
+				return nil, err
+			}
+		}
+
+		reduce(k, k-1)
+
+		// Lovász condition: bb[k] >= (delta - mu[k][k-1]^2) * bb[k-1].
+		lovasz.Mul(mu[k][k-1], mu[k][k-1])
+		lovasz.Sub(delta, lovasz).Mul(lovasz, bb[k-1])
+		if bb[k].Cmp(lovasz) < 0 {
+			swap(k, kmax)
+			if k > 1 {
+				k--
+			}
+			continue
+		}
+
+		for l := k - 2; l >= 0; l-- {
+			reduce(k, l)
+		}
+		k++
+	}
+
+	return b, nil
+}
+
+// ratDot returns the dot product of x and y.
+func ratDot(x, y []*big.Rat) *big.Rat {
+	var (
+		res = new(big.Rat)
+		tmp = new(big.Rat)
+	)
+	for i := range x {
+		res.Add(res, tmp.Mul(x[i], y[i]))
+	}
+	return res
+}
+
+// ratRound returns r rounded to the nearest integer, with halves
+// rounded up.
+func ratRound(r *big.Rat) *big.Int {
+	res := new(big.Rat).Add(r, big.NewRat(1, 2))
+	return ratFloor(res)
+}
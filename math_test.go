@@ -0,0 +1,182 @@
+package cryptopals
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestTrialDivision(t *testing.T) {
+	t.Parallel()
+	// 2^3 * 3 * 101^2 * 1000003
+	n := big.NewInt(8 * 3 * 101 * 101 * 1000003)
+
+	factors, rem := TrialDivision(n, 1000)
+	want := []struct {
+		p int64
+		k int
+	}{{2, 3}, {3, 1}, {101, 2}}
+
+	if len(factors) != len(want) {
+		t.Fatalf("got %d factors, want %d", len(factors), len(want))
+	}
+	for i, f := range factors {
+		if f.P.Int64() != want[i].p || f.K != want[i].k {
+			t.Errorf("got %v^%d, want %d^%d", f.P, f.K, want[i].p, want[i].k)
+		}
+	}
+	if rem.Int64() != 1000003 {
+		t.Errorf("got cofactor %v, want 1000003", rem)
+	}
+}
+
+func TestCRT(t *testing.T) {
+	t.Parallel()
+	var (
+		residues = []*big.Int{big.NewInt(2), big.NewInt(3), big.NewInt(2)}
+		moduli   = []*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(7)}
+	)
+
+	x, n, err := CRT(residues, moduli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Int64() != 23 || n.Int64() != 105 {
+		t.Errorf("got %v mod %v, want 23 mod 105", x, n)
+	}
+
+	_, _, err = CRT(residues[:2], []*big.Int{big.NewInt(4), big.NewInt(6)})
+	if err == nil {
+		t.Errorf("no error for moduli that aren't coprime")
+	}
+}
+
+func TestPohligHellman(t *testing.T) {
+	t.Parallel()
+	var (
+		p   = big.NewInt(8101) // p-1 = 2^2 * 3^4 * 5^2
+		g   = big.NewInt(6)    // A generator.
+		grp = ModPGroup{P: p}
+	)
+	factors, _ := TrialDivision(new(big.Int).Sub(p, big1), 100)
+
+	for _, x := range []int64{0, 1, 2, 81, 1234, 8099} {
+		y := new(big.Int).Exp(g, big.NewInt(x), p)
+		got, err := PohligHellman(grp, g, y, factors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Int64() != x {
+			t.Errorf("got %v, want %d", got, x)
+		}
+	}
+}
+
+func TestLLL(t *testing.T) {
+	t.Parallel()
+	basis := helperRatMatrix([][]int64{
+		{1, 1, 1},
+		{-1, 0, 2},
+		{3, 5, 6},
+	})
+	want := helperRatMatrix([][]int64{
+		{0, 1, 0},
+		{1, 0, 1},
+		{-1, 0, 2},
+	})
+
+	got, err := LLL(basis, big.NewRat(3, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if got[i][j].Cmp(want[i][j]) != 0 {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+	}
+	if basis[2][0].Cmp(big.NewRat(3, 1)) != 0 {
+		t.Error("basis was modified")
+	}
+}
+
+func TestLLL_Reduced(t *testing.T) {
+	t.Parallel()
+	basis := helperRatMatrix([][]int64{
+		{1, 0, 0, 0, 0, 575},
+		{0, 1, 0, 0, 0, 436},
+		{0, 0, 1, 0, 0, 1586},
+		{0, 0, 0, 1, 0, 1030},
+		{0, 0, 0, 0, 1, 1921},
+		{0, 0, 0, 0, 0, 5812},
+	})
+	delta := big.NewRat(99, 100)
+
+	got, err := LLL(basis, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the definition: size-reduced, and the Lovász condition.
+	n := len(got)
+	var (
+		star = make([][]*big.Rat, n)
+		bb   = make([]*big.Rat, n)
+		mu   = make([][]*big.Rat, n)
+	)
+	for i := range got {
+		star[i] = make([]*big.Rat, len(got[i]))
+		for k := range got[i] {
+			star[i][k] = new(big.Rat).Set(got[i][k])
+		}
+		mu[i] = make([]*big.Rat, n)
+		for j := 0; j < i; j++ {
+			mu[i][j] = new(big.Rat).Quo(ratDot(got[i], star[j]), bb[j])
+			for k := range star[i] {
+				star[i][k].Sub(star[i][k], new(big.Rat).Mul(mu[i][j], star[j][k]))
+			}
+			if new(big.Rat).Abs(mu[i][j]).Cmp(big.NewRat(1, 2)) > 0 {
+				t.Errorf("mu[%d][%d] = %v, want |mu| <= 1/2", i, j, mu[i][j])
+			}
+		}
+		bb[i] = ratDot(star[i], star[i])
+	}
+	for k := 1; k < n; k++ {
+		rhs := new(big.Rat).Mul(mu[k][k-1], mu[k][k-1])
+		rhs.Sub(delta, rhs).Mul(rhs, bb[k-1])
+		if bb[k].Cmp(rhs) < 0 {
+			t.Errorf("Lovász condition fails at %d", k)
+		}
+	}
+
+	// It's the same lattice, so the volume hasn't changed.
+	vol := big.NewRat(1, 1)
+	for _, x := range bb {
+		vol.Mul(vol, x)
+	}
+	if want := big.NewRat(5812*5812, 1); vol.Cmp(want) != 0 {
+		t.Errorf("got squared volume %v, want %v", vol, want)
+	}
+}
+
+func TestLLL_Dependent(t *testing.T) {
+	t.Parallel()
+	basis := helperRatMatrix([][]int64{
+		{1, 2},
+		{2, 4},
+	})
+	if _, err := LLL(basis, big.NewRat(3, 4)); err == nil {
+		t.Error("got nil error for dependent vectors")
+	}
+}
+
+func helperRatMatrix(rows [][]int64) [][]*big.Rat {
+	res := make([][]*big.Rat, len(rows))
+	for i, row := range rows {
+		res[i] = make([]*big.Rat, len(row))
+		for j, x := range row {
+			res[i][j] = big.NewRat(x, 1)
+		}
+	}
+	return res
+}
@@ -0,0 +1,386 @@
+package cryptopals
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// CFBCipher represents a CFB mode cipher, with segments as long as
+// the block size.
+type CFBCipher struct {
+	b cipher.Block
+}
+
+// NewCFBCipher returns a new CFBCipher that uses AES.
+func NewCFBCipher(key []byte) (*CFBCipher, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCFBCipherFromBlock(b), nil
+}
+
+// NewCFBCipherFromBlock returns a new CFBCipher that uses b.
+func NewCFBCipherFromBlock(b cipher.Block) *CFBCipher {
+	return &CFBCipher{b: b}
+}
+
+// Encrypt encrypts a plaintext of any length using an IV.
+func (c *CFBCipher) Encrypt(pt, iv []byte) ([]byte, error) {
+	return c.crypt(pt, iv, false)
+}
+
+// Decrypt decrypts a ciphertext of any length using an IV.
+func (c *CFBCipher) Decrypt(ct, iv []byte) ([]byte, error) {
+	return c.crypt(ct, iv, true)
+}
+
+// crypt XORs src with the encryption of the previous ciphertext block.
+func (c *CFBCipher) crypt(src, iv []byte, decrypt bool) ([]byte, error) {
+	bs := c.b.BlockSize()
+	if len(iv) != bs {
+		return nil, fmt.Errorf("invalid IV")
+	}
+
+	var (
+		dst  = make([]byte, len(src))
+		ks   = make([]byte, bs)
+		prev = iv
+	)
+	for i := 0; i < len(src); i += bs {
+		c.b.Encrypt(ks, prev)
+		for j := i; j < len(src) && j < i+bs; j++ {
+			dst[j] = src[j] ^ ks[j-i]
+		}
+		if decrypt {
+			prev = src[i:]
+		} else {
+			prev = dst[i:]
+		}
+	}
+	return dst, nil
+}
+
+// OFBCipher represents an OFB mode cipher.
+type OFBCipher struct {
+	b cipher.Block
+}
+
+// NewOFBCipher returns a new OFBCipher that uses AES.
+func NewOFBCipher(key []byte) (*OFBCipher, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewOFBCipherFromBlock(b), nil
+}
+
+// NewOFBCipherFromBlock returns a new OFBCipher that uses b.
+func NewOFBCipherFromBlock(b cipher.Block) *OFBCipher {
+	return &OFBCipher{b: b}
+}
+
+// Encrypt encrypts a plaintext of any length using an IV.
+func (c *OFBCipher) Encrypt(pt, iv []byte) ([]byte, error) {
+	return c.crypt(pt, iv)
+}
+
+// Decrypt decrypts a ciphertext of any length using an IV. It's the
+// same as encryption.
+func (c *OFBCipher) Decrypt(ct, iv []byte) ([]byte, error) {
+	return c.crypt(ct, iv)
+}
+
+// crypt XORs src with the keystream, which is the IV encrypted over
+// and over.
+func (c *OFBCipher) crypt(src, iv []byte) ([]byte, error) {
+	bs := c.b.BlockSize()
+	if len(iv) != bs {
+		return nil, fmt.Errorf("invalid IV")
+	}
+
+	var (
+		dst = make([]byte, len(src))
+		ks  = append([]byte(nil), iv...)
+	)
+	for i := 0; i < len(src); i += bs {
+		c.b.Encrypt(ks, ks)
+		for j := i; j < len(src) && j < i+bs; j++ {
+			dst[j] = src[j] ^ ks[j-i]
+		}
+	}
+	return dst, nil
+}
+
+// PCBCCipher represents a PCBC mode cipher. Each block is XORed with
+// both the plaintext and the ciphertext of the block before it.
+type PCBCCipher struct {
+	b cipher.Block
+}
+
+// NewPCBCCipher returns a new PCBCCipher that uses AES.
+func NewPCBCCipher(key []byte) (*PCBCCipher, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewPCBCCipherFromBlock(b), nil
+}
+
+// NewPCBCCipherFromBlock returns a new PCBCCipher that uses b.
+func NewPCBCCipherFromBlock(b cipher.Block) *PCBCCipher {
+	return &PCBCCipher{b: b}
+}
+
+// Encrypt encrypts a plaintext using an IV.
+func (c *PCBCCipher) Encrypt(pt, iv []byte) ([]byte, error) {
+	bs := c.b.BlockSize()
+	if len(pt)%bs != 0 {
+		return nil, fmt.Errorf("invalid plaintext")
+	}
+	if len(iv) != bs {
+		return nil, fmt.Errorf("invalid IV")
+	}
+
+	var (
+		ct    = make([]byte, len(pt))
+		chain = append([]byte(nil), iv...)
+	)
+	for i := 0; i < len(pt); i += bs {
+		for j := range chain {
+			chain[j] ^= pt[i+j]
+		}
+		c.b.Encrypt(ct[i:i+bs], chain)
+		for j := range chain {
+			chain[j] = pt[i+j] ^ ct[i+j]
+		}
+	}
+	return ct, nil
+}
+
+// Decrypt decrypts a ciphertext using an IV.
+func (c *PCBCCipher) Decrypt(ct, iv []byte) ([]byte, error) {
+	bs := c.b.BlockSize()
+	if len(ct)%bs != 0 {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	if len(iv) != bs {
+		return nil, fmt.Errorf("invalid IV")
+	}
+
+	var (
+		pt    = make([]byte, len(ct))
+		chain = append([]byte(nil), iv...)
+	)
+	for i := 0; i < len(ct); i += bs {
+		c.b.Decrypt(pt[i:i+bs], ct[i:i+bs])
+		for j := range chain {
+			pt[i+j] ^= chain[j]
+			chain[j] = pt[i+j] ^ ct[i+j]
+		}
+	}
+	return pt, nil
+}
+
+// XTSCipher represents an XTS-AES mode cipher, as used for disk
+// encryption. Each sector is encrypted on its own, with its number
+// as the tweak.
+type XTSCipher struct {
+	data, tweak cipher.Block
+}
+
+// NewXTSCipher returns a new XTSCipher. The key is two AES keys of
+// the same size joined together: the first encrypts the data, and the
+// second encrypts the tweaks.
+func NewXTSCipher(key []byte) (*XTSCipher, error) {
+	if len(key)%2 != 0 {
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+	data, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	tweak, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	return NewXTSCipherFromBlocks(data, tweak)
+}
+
+// NewXTSCipherFromBlocks returns a new XTSCipher that encrypts the
+// data with one block cipher and the tweaks with the other. The
+// tweaks live in GF(2^128), so both must have 128-bit blocks.
+func NewXTSCipherFromBlocks(data, tweak cipher.Block) (*XTSCipher, error) {
+	if data.BlockSize() != aes.BlockSize || tweak.BlockSize() != aes.BlockSize {
+		return nil, fmt.Errorf("XTS needs 128-bit blocks")
+	}
+	return &XTSCipher{data: data, tweak: tweak}, nil
+}
+
+// Encrypt encrypts a sector. The plaintext must be at least one block
+// long, and if it isn't whole blocks, the last two blocks use
+// ciphertext stealing.
+func (c *XTSCipher) Encrypt(pt []byte, sector uint64) ([]byte, error) {
+	return c.crypt(pt, sector, false)
+}
+
+// Decrypt decrypts a sector.
+func (c *XTSCipher) Decrypt(ct []byte, sector uint64) ([]byte, error) {
+	return c.crypt(ct, sector, true)
+}
+
+// crypt encrypts or decrypts a sector. Block j is XORed with the
+// tweak T*α^j before and after the block cipher, where T is the
+// encrypted sector number.
+func (c *XTSCipher) crypt(src []byte, sector uint64, decrypt bool) ([]byte, error) {
+	if len(src) < aes.BlockSize {
+		return nil, fmt.Errorf("sector too short")
+	}
+
+	var (
+		dst   = make([]byte, len(src))
+		t     = make([]byte, aes.BlockSize)
+		full  = len(src) / aes.BlockSize
+		extra = len(src) % aes.BlockSize
+	)
+	binary.LittleEndian.PutUint64(t, sector)
+	c.tweak.Encrypt(t, t)
+
+	// With ciphertext stealing, the last full block is done separately.
+	if extra != 0 {
+		full--
+	}
+	for j := 0; j < full; j++ {
+		i := j * aes.BlockSize
+		c.block(dst[i:i+aes.BlockSize], src[i:i+aes.BlockSize], t, decrypt)
+		xtsMulAlpha(t)
+	}
+	if extra == 0 {
+		return dst, nil
+	}
+
+	// The last full block is done with the last tweak, and the partial
+	// block steals the end of its output. Decryption needs the tweaks
+	// in the other order.
+	var (
+		i     = full * aes.BlockSize
+		last  = src[i : i+aes.BlockSize]
+		tail  = src[i+aes.BlockSize:]
+		t2    = append([]byte(nil), t...)
+		block = make([]byte, aes.BlockSize)
+	)
+	xtsMulAlpha(t2)
+	if decrypt {
+		t, t2 = t2, t
+	}
+	c.block(block, last, t, decrypt)
+	copy(dst[i+aes.BlockSize:], block[:extra])
+	copy(block, tail)
+	c.block(dst[i:i+aes.BlockSize], block, t2, decrypt)
+	return dst, nil
+}
+
+// block encrypts or decrypts one block with a tweak.
+func (c *XTSCipher) block(dst, src, t []byte, decrypt bool) {
+	for j := range t {
+		dst[j] = src[j] ^ t[j]
+	}
+	if decrypt {
+		c.data.Decrypt(dst, dst)
+	} else {
+		c.data.Encrypt(dst, dst)
+	}
+	for j := range t {
+		dst[j] ^= t[j]
+	}
+}
+
+// xtsMulAlpha multiplies a tweak by α, the polynomial x, in XTS's
+// little-endian representation of GF(2^128).
+func xtsMulAlpha(t []byte) {
+	var carry byte
+	for i := range t {
+		next := t[i] >> 7
+		t[i] = t[i]<<1 | carry
+		carry = next
+	}
+	if carry == 1 {
+		t[0] ^= 0x87
+	}
+}
+
+// OFBKeystreamReuseDecrypt decrypts ct, given another plaintext and
+// its ciphertext that used the same key and IV. OFB's keystream
+// doesn't depend on the plaintext, so reusing an IV makes it a
+// many-time pad. It only decrypts as much of ct as the known pair
+// covers.
+func OFBKeystreamReuseDecrypt(knownPT, knownCT, ct []byte) []byte {
+	n := len(ct)
+	if len(knownPT) < n {
+		n = len(knownPT)
+	}
+	if len(knownCT) < n {
+		n = len(knownCT)
+	}
+	pt := make([]byte, n)
+	for i := range pt {
+		pt[i] = ct[i] ^ knownCT[i] ^ knownPT[i]
+	}
+	return pt
+}
+
+// CFBBitflip returns a copy of a CFB ciphertext that decrypts to want
+// instead of have at offset i. The change passes straight through to
+// the plaintext, but it also changes the input to the next block's
+// keystream, so the block after the last changed byte decrypts to
+// garbage. Everything after that decrypts as before.
+func CFBBitflip(ct []byte, i int, have, want []byte) ([]byte, error) {
+	if len(have) != len(want) || i < 0 || i+len(have) > len(ct) {
+		return nil, fmt.Errorf("invalid range")
+	}
+	res := append([]byte(nil), ct...)
+	for j := range have {
+		res[i+j] ^= have[j] ^ want[j]
+	}
+	return res, nil
+}
+
+// PCBCSwapBlocks returns a copy of a PCBC ciphertext with blocks i and
+// i+1 swapped. PCBC was meant to spread any change to the end of the
+// message, but the chaining value after the two blocks only depends on
+// which blocks came before it, not their order. So only the swapped
+// blocks decrypt differently, and a check at the end of the message
+// doesn't notice.
+func PCBCSwapBlocks(ct []byte, i, blockSize int) ([]byte, error) {
+	if len(ct)%blockSize != 0 || i < 0 || (i+2)*blockSize > len(ct) {
+		return nil, fmt.Errorf("invalid block")
+	}
+	var (
+		res = append([]byte(nil), ct...)
+		a   = res[i*blockSize : (i+1)*blockSize]
+		b   = res[(i+1)*blockSize : (i+2)*blockSize]
+	)
+	for j := range a {
+		a[j], b[j] = b[j], a[j]
+	}
+	return res, nil
+}
+
+// XTSReplayBlock returns a copy of an XTS ciphertext with block j
+// replaced by block j of an older ciphertext of the same sector. The
+// tweak ties each block to its sector and position, so a block moved
+// anywhere else decrypts to garbage, but one put back in the same
+// place decrypts to its old plaintext. Since there's no
+// authentication, and no chaining between blocks, nothing else in the
+// sector changes.
+func XTSReplayBlock(ct, old []byte, j int) ([]byte, error) {
+	i := j * aes.BlockSize
+	if len(ct) != len(old) || j < 0 || i+aes.BlockSize > len(ct) {
+		return nil, fmt.Errorf("invalid block")
+	}
+	res := append([]byte(nil), ct...)
+	copy(res[i:i+aes.BlockSize], old[i:])
+	return res, nil
+}
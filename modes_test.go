@@ -0,0 +1,397 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"testing"
+)
+
+// NIST SP 800-38A, F.3.13 and F.4.1.
+const (
+	sp80038AKey = "2b7e151628aed2a6abf7158809cf4f3c"
+	sp80038AIV  = "000102030405060708090a0b0c0d0e0f"
+	sp80038APT  = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+)
+
+func TestCFBCipher(t *testing.T) {
+	t.Parallel()
+	var (
+		key  = HelperDecodeHex(t, sp80038AKey)
+		iv   = HelperDecodeHex(t, sp80038AIV)
+		pt   = HelperDecodeHex(t, sp80038APT)
+		want = HelperDecodeHex(t, "3b3fd92eb72dad20333449f8e83cfb4ac8a64537a0b3a93fcde3cdad9f1ce58b26751f67a3cbb140b1808cf187a4f4dfc04b05357c5d1c0eeac4c66f9ff7f2e6")
+	)
+	c, err := NewCFBCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCheckIVMode(t, c.Encrypt, c.Decrypt, pt, iv, want)
+
+	// Partial blocks, against crypto/cipher.
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Encrypt(pt[:37], iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := make([]byte, 37)
+	cipher.NewCFBEncrypter(b, iv).XORKeyStream(std, pt[:37])
+	if !bytes.Equal(got, std) {
+		t.Errorf("got %x, want %x", got, std)
+	}
+}
+
+func TestOFBCipher(t *testing.T) {
+	t.Parallel()
+	var (
+		key  = HelperDecodeHex(t, sp80038AKey)
+		iv   = HelperDecodeHex(t, sp80038AIV)
+		pt   = HelperDecodeHex(t, sp80038APT)
+		want = HelperDecodeHex(t, "3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed8259740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e")
+	)
+	c, err := NewOFBCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	helperCheckIVMode(t, c.Encrypt, c.Decrypt, pt, iv, want)
+
+	got, err := c.Encrypt(pt[:37], iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want[:37]) {
+		t.Errorf("got %x, want %x", got, want[:37])
+	}
+}
+
+func TestPCBCCipher(t *testing.T) {
+	t.Parallel()
+	// There are no standard PCBC vectors, but its first block is the
+	// same as CBC's, and each block after that is CBC with the
+	// plaintext XORed in as well.
+	var (
+		key = HelperDecodeHex(t, sp80038AKey)
+		iv  = HelperDecodeHex(t, sp80038AIV)
+		pt  = HelperDecodeHex(t, sp80038APT)
+		cbc = HelperDecodeHex(t, "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7")
+	)
+	c, err := NewPCBCCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := make([]byte, len(pt))
+	copy(want, cbc[:16])
+	for i := 16; i < len(pt); i += 16 {
+		in, _ := XORBytes(pt[i:i+16], pt[i-16:i])
+		in, _ = XORBytes(in, want[i-16:i])
+		b.Encrypt(want[i:], in)
+	}
+	helperCheckIVMode(t, c.Encrypt, c.Decrypt, pt, iv, want)
+	if _, err := c.Encrypt(pt[:20], iv); err == nil {
+		t.Error("encrypted a partial block")
+	}
+}
+
+func TestModes_AnyBlock(t *testing.T) {
+	t.Parallel()
+	d, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, b := range map[string]cipher.Block{
+		"DES": d,
+		"toy": toyBlock(0x5c),
+	} {
+		var (
+			bs = b.BlockSize()
+			iv = bytes.Repeat([]byte{7}, bs)
+			pt = bytes.Repeat([]byte("yellow submarine"), 3)[:5*bs]
+		)
+
+		// CFB and OFB against crypto/cipher, with a partial block.
+		want := make([]byte, len(pt)-1)
+		cipher.NewCFBEncrypter(b, iv).XORKeyStream(want, pt[:len(want)])
+		cfb := NewCFBCipherFromBlock(b)
+		helperCheckIVMode(t, cfb.Encrypt, cfb.Decrypt, pt[:len(want)], iv, want)
+
+		cipher.NewOFB(b, iv).XORKeyStream(want, pt[:len(want)])
+		ofb := NewOFBCipherFromBlock(b)
+		helperCheckIVMode(t, ofb.Encrypt, ofb.Decrypt, pt[:len(want)], iv, want)
+
+		// PCBC's first block is CBC's.
+		pcbc := NewPCBCCipherFromBlock(b)
+		ct, err := pcbc.Encrypt(pt, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cbc := make([]byte, bs)
+		cipher.NewCBCEncrypter(b, iv).CryptBlocks(cbc, pt[:bs])
+		if !bytes.Equal(ct[:bs], cbc) {
+			t.Errorf("%s PCBC: got first block %x, want %x", name, ct[:bs], cbc)
+		}
+		helperCheckIVMode(t, pcbc.Encrypt, pcbc.Decrypt, pt, iv, ct)
+
+		if _, err := NewXTSCipherFromBlocks(b, b); err == nil {
+			t.Errorf("%s XTS: no error for %d-byte blocks", name, bs)
+		}
+	}
+}
+
+func TestXTSCipher(t *testing.T) {
+	t.Parallel()
+	// IEEE 1619-2007, vectors 1, 2 and 15. The last uses ciphertext
+	// stealing. The standard lists the sector numbers' bytes in
+	// little-endian order.
+	cases := []struct {
+		key    string
+		sector uint64
+		pt, ct string
+	}{
+		{
+			key:    "0000000000000000000000000000000000000000000000000000000000000000",
+			sector: 0,
+			pt:     "0000000000000000000000000000000000000000000000000000000000000000",
+			ct:     "917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+		},
+		{
+			key:    "1111111111111111111111111111111122222222222222222222222222222222",
+			sector: 0x3333333333,
+			pt:     "4444444444444444444444444444444444444444444444444444444444444444",
+			ct:     "c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+		},
+		{
+			key:    "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0",
+			sector: 0x123456789a,
+			pt:     "000102030405060708090a0b0c0d0e0f10",
+			ct:     "6c1625db4671522d3d7599601de7ca09ed",
+		},
+	}
+	for i, tc := range cases {
+		var (
+			key  = HelperDecodeHex(t, tc.key)
+			pt   = HelperDecodeHex(t, tc.pt)
+			want = HelperDecodeHex(t, tc.ct)
+		)
+		c, err := NewXTSCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Encrypt(pt, tc.sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("case %d: got %x, want %x", i+1, got, want)
+		}
+		dec, err := c.Decrypt(got, tc.sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec, pt) {
+			t.Errorf("case %d: got %x, want %x", i+1, dec, pt)
+		}
+	}
+}
+
+func TestXTSCipher_Stealing(t *testing.T) {
+	t.Parallel()
+	c, err := NewXTSCipher(bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := bytes.Repeat([]byte("yellow submarine"), 4)
+	full, err := c.Encrypt(pt[:48], 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 16; n <= len(pt); n++ {
+		ct, err := c.Encrypt(pt[:n], 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ct) != n {
+			t.Errorf("len %d: got %d bytes of ciphertext", n, len(ct))
+		}
+		got, err := c.Decrypt(ct, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, pt[:n]) {
+			t.Errorf("len %d: got %q, want %q", n, got, pt[:n])
+		}
+		// Stealing only touches the last two blocks.
+		if m := (n/16 - 1) * 16; n > 32 && !bytes.Equal(ct[:m], full[:m]) {
+			t.Errorf("len %d: stealing changed earlier blocks", n)
+		}
+	}
+	if _, err := c.Encrypt(pt[:15], 7); err == nil {
+		t.Error("encrypted less than a block")
+	}
+}
+
+func TestOFBKeystreamReuseDecrypt(t *testing.T) {
+	t.Parallel()
+	c, err := NewOFBCipher(bytes.Repeat([]byte{1}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		iv    = bytes.Repeat([]byte{2}, 16)
+		known = []byte("a message that the attacker already knows all about")
+		want  = []byte("and a secret one that it doesn't")
+	)
+	knownCT, err := c.Encrypt(known, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := c.Encrypt(want, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := OFBKeystreamReuseDecrypt(known, knownCT, ct); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCFBBitflip(t *testing.T) {
+	t.Parallel()
+	c, err := NewCFBCipher(bytes.Repeat([]byte{1}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		iv = bytes.Repeat([]byte{2}, 16)
+		pt = []byte("from=alice;to=bob;amount=0000100;memo=rent for the month of june")
+	)
+	ct, err := c.Encrypt(pt, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := bytes.Index(pt, []byte("0000100"))
+	forged, err := CFBBitflip(ct, i, []byte("0000100"), []byte("9999999"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(forged, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The change is in block 1, so block 2 is garbage and the rest is
+	// untouched.
+	if want := []byte("from=alice;to=bob;amount=9999999"); !bytes.Equal(got[:32], want) {
+		t.Errorf("got %q, want %q", got[:32], want)
+	}
+	if bytes.Equal(got[32:48], pt[32:48]) {
+		t.Error("the next block didn't change")
+	}
+	if !bytes.Equal(got[48:], pt[48:]) {
+		t.Errorf("got %q, want %q", got[48:], pt[48:])
+	}
+}
+
+func TestPCBCSwapBlocks(t *testing.T) {
+	t.Parallel()
+	c, err := NewPCBCCipher(bytes.Repeat([]byte{1}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		iv = bytes.Repeat([]byte{2}, 16)
+		pt = []byte("block zero......block one.......block two.......checksum block...")[:64]
+	)
+	ct, err := c.Encrypt(pt, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := PCBCSwapBlocks(ct, 1, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(forged, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:16], pt[:16]) || !bytes.Equal(got[48:], pt[48:]) {
+		t.Errorf("got %q, want %q outside the swapped blocks", got, pt)
+	}
+	if bytes.Equal(got[16:48], pt[16:48]) {
+		t.Error("the swapped blocks didn't change")
+	}
+}
+
+func TestXTSReplayBlock(t *testing.T) {
+	t.Parallel()
+	c, err := NewXTSCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		oldPT = []byte("balance: $000100balance: $000200balance: $000300")
+		newPT = []byte("balance: $000000balance: $000000balance: $000000")
+	)
+	oldCT, err := c.Encrypt(oldPT, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCT, err := c.Encrypt(newPT, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := XTSReplayBlock(newCT, oldCT, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(forged, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("balance: $000000balance: $000200balance: $000000")
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Moved to another position, the block is garbage.
+	moved := append([]byte(nil), newCT...)
+	copy(moved[32:], oldCT[16:32])
+	got, err = c.Decrypt(moved, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got[32:], oldPT[16:32]) {
+		t.Error("a moved block decrypted to its old plaintext")
+	}
+}
+
+// helperCheckIVMode checks that encrypt gives want, and decrypt
+// undoes it.
+func helperCheckIVMode(tb testing.TB, encrypt, decrypt func([]byte, []byte) ([]byte, error), pt, iv, want []byte) {
+	tb.Helper()
+	got, err := encrypt(pt, iv)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		tb.Errorf("got %x, want %x", got, want)
+	}
+	dec, err := decrypt(got, iv)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !bytes.Equal(dec, pt) {
+		tb.Errorf("got %x, want %x", dec, pt)
+	}
+}
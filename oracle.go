@@ -0,0 +1,548 @@
+package cryptopals
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EncryptOracle encrypts chosen plaintexts.
+type EncryptOracle interface {
+	Encrypt(pt []byte) ([]byte, error)
+}
+
+// EncryptOracleFunc adapts a function to an EncryptOracle.
+type EncryptOracleFunc func(pt []byte) ([]byte, error)
+
+// Encrypt returns f(pt).
+func (f EncryptOracleFunc) Encrypt(pt []byte) ([]byte, error) {
+	return f(pt)
+}
+
+// LenOracle encrypts chosen plaintexts, and only reveals the length
+// of the ciphertext.
+type LenOracle interface {
+	Len(pt []byte) (int, error)
+}
+
+// LenOracleFunc adapts a function to a LenOracle.
+type LenOracleFunc func(pt []byte) (int, error)
+
+// Len returns f(pt).
+func (f LenOracleFunc) Len(pt []byte) (int, error) {
+	return f(pt)
+}
+
+// ValidityOracle decrypts a ciphertext, and only reveals whether
+// the result passed some check, like having valid padding.
+type ValidityOracle interface {
+	Valid(ct []byte) (bool, error)
+}
+
+// ValidityOracleFunc adapts a function to a ValidityOracle.
+type ValidityOracleFunc func(ct []byte) (bool, error)
+
+// Valid returns f(ct).
+func (f ValidityOracleFunc) Valid(ct []byte) (bool, error) {
+	return f(ct)
+}
+
+// ParityOracle decrypts a ciphertext, and only reveals whether the
+// plaintext is even.
+type ParityOracle interface {
+	Even(ct []byte) (bool, error)
+}
+
+// ParityOracleFunc adapts a function to a ParityOracle.
+type ParityOracleFunc func(ct []byte) (bool, error)
+
+// Even returns f(ct).
+func (f ParityOracleFunc) Even(ct []byte) (bool, error) {
+	return f(ct)
+}
+
+// KeyExchangeOracle completes a key exchange with a chosen public
+// key, and responds with a message and its MAC under the shared
+// secret.
+type KeyExchangeOracle interface {
+	Exchange(pub []byte) (msg, mac []byte, err error)
+}
+
+// KeyExchangeOracleFunc adapts a function to a KeyExchangeOracle.
+type KeyExchangeOracleFunc func(pub []byte) (msg, mac []byte, err error)
+
+// Exchange returns f(pub).
+func (f KeyExchangeOracleFunc) Exchange(pub []byte) (msg, mac []byte, err error) {
+	return f(pub)
+}
+
+// TransferOracle signs money transfer requests from one account,
+// and nobody else's.
+type TransferOracle interface {
+	ID() int
+	Transfer(to, amount int) ([]byte, error)
+	TransferList(txs []CBCMACTx) ([]byte, error)
+}
+
+// SignOracle signs chosen messages, and checks signatures.
+type SignOracle interface {
+	Sign(msg []byte) ([]byte, error)
+	Verify(msg, sig []byte) (bool, error)
+}
+
+// BudgetError is returned by an oracle from an OracleMeter once its
+// query budget is spent.
+type BudgetError struct {
+	Budget int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("query budget of %d spent", e.Budget)
+}
+
+// OracleMeter counts queries to the oracles it wraps, so attacks can
+// be measured and compared. All of its oracles share one count, and
+// are safe for concurrent use if the oracles they wrap are.
+type OracleMeter struct {
+	// Budget is the most queries allowed, or 0 for no limit. Queries
+	// over budget fail with a *BudgetError without reaching the
+	// oracle.
+	Budget int
+
+	// Latency is added to every query.
+	Latency time.Duration
+
+	// Interval is the least time between the starts of two queries,
+	// or 0 for no rate limit.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	queries int
+	next    time.Time
+}
+
+// Queries returns how many queries have reached the wrapped oracles.
+func (m *OracleMeter) Queries() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queries
+}
+
+// query counts a query, and waits for the rate limit and latency.
+func (m *OracleMeter) query() error {
+	m.mu.Lock()
+	if m.Budget > 0 && m.queries >= m.Budget {
+		m.mu.Unlock()
+		return &BudgetError{Budget: m.Budget}
+	}
+	m.queries++
+	var (
+		now   = time.Now()
+		start = now
+	)
+	if m.next.After(now) {
+		start = m.next
+	}
+	m.next = start.Add(m.Interval)
+	m.mu.Unlock()
+
+	time.Sleep(start.Sub(now) + m.Latency)
+	return nil
+}
+
+// Encrypt returns an EncryptOracle that queries o through the meter.
+func (m *OracleMeter) Encrypt(o EncryptOracle) EncryptOracle {
+	return EncryptOracleFunc(func(pt []byte) ([]byte, error) {
+		if err := m.query(); err != nil {
+			return nil, err
+		}
+		return o.Encrypt(pt)
+	})
+}
+
+// Len returns a LenOracle that queries o through the meter.
+func (m *OracleMeter) Len(o LenOracle) LenOracle {
+	return LenOracleFunc(func(pt []byte) (int, error) {
+		if err := m.query(); err != nil {
+			return 0, err
+		}
+		return o.Len(pt)
+	})
+}
+
+// Validity returns a ValidityOracle that queries o through the meter.
+func (m *OracleMeter) Validity(o ValidityOracle) ValidityOracle {
+	return ValidityOracleFunc(func(ct []byte) (bool, error) {
+		if err := m.query(); err != nil {
+			return false, err
+		}
+		return o.Valid(ct)
+	})
+}
+
+// Parity returns a ParityOracle that queries o through the meter.
+func (m *OracleMeter) Parity(o ParityOracle) ParityOracle {
+	return ParityOracleFunc(func(ct []byte) (bool, error) {
+		if err := m.query(); err != nil {
+			return false, err
+		}
+		return o.Even(ct)
+	})
+}
+
+// KeyExchange returns a KeyExchangeOracle that queries o through the
+// meter.
+func (m *OracleMeter) KeyExchange(o KeyExchangeOracle) KeyExchangeOracle {
+	return KeyExchangeOracleFunc(func(pub []byte) ([]byte, []byte, error) {
+		if err := m.query(); err != nil {
+			return nil, nil, err
+		}
+		return o.Exchange(pub)
+	})
+}
+
+// meteredTransferOracle is the TransferOracle from
+// OracleMeter.Transfer.
+type meteredTransferOracle struct {
+	m *OracleMeter
+	o TransferOracle
+}
+
+// Transfer returns a TransferOracle that queries o through the meter.
+// ID isn't a query, so it isn't counted.
+func (m *OracleMeter) Transfer(o TransferOracle) TransferOracle {
+	return meteredTransferOracle{m: m, o: o}
+}
+
+func (t meteredTransferOracle) ID() int {
+	return t.o.ID()
+}
+
+func (t meteredTransferOracle) Transfer(to, amount int) ([]byte, error) {
+	if err := t.m.query(); err != nil {
+		return nil, err
+	}
+	return t.o.Transfer(to, amount)
+}
+
+func (t meteredTransferOracle) TransferList(txs []CBCMACTx) ([]byte, error) {
+	if err := t.m.query(); err != nil {
+		return nil, err
+	}
+	return t.o.TransferList(txs)
+}
+
+// meteredSignOracle is the SignOracle from OracleMeter.Sign.
+type meteredSignOracle struct {
+	m *OracleMeter
+	o SignOracle
+}
+
+// Sign returns a SignOracle that queries o through the meter. Signing
+// and verifying both count as queries.
+func (m *OracleMeter) Sign(o SignOracle) SignOracle {
+	return meteredSignOracle{m: m, o: o}
+}
+
+func (s meteredSignOracle) Sign(msg []byte) ([]byte, error) {
+	if err := s.m.query(); err != nil {
+		return nil, err
+	}
+	return s.o.Sign(msg)
+}
+
+func (s meteredSignOracle) Verify(msg, sig []byte) (bool, error) {
+	if err := s.m.query(); err != nil {
+		return false, err
+	}
+	return s.o.Verify(msg, sig)
+}
+
+// Mode is a guess at how an oracle encrypts.
+type Mode int
+
+// Modes that DetectMode and ProfileOracle can tell apart.
+const (
+	ModeUnknown Mode = iota
+	ModeECB
+	ModeCBC    // Or any other mode that chains blocks together.
+	ModeStream // CTR, OFB, or anything else that doesn't pad.
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeUnknown:
+		return "unknown"
+	case ModeECB:
+		return "ECB"
+	case ModeCBC:
+		return "CBC"
+	case ModeStream:
+		return "stream"
+	}
+	return "unknown"
+}
+
+// OracleProfile describes an encryption oracle. Each guess comes with
+// a confidence between 0 and 1. These are heuristics rather than
+// probabilities: each is the share of the measurements behind the
+// guess that agreed with it, or for determinism, how unlikely a coin
+// flip is to have come out the same every time.
+type OracleProfile struct {
+	// BlockSize is 1 for stream modes.
+	BlockSize           int
+	BlockSizeConfidence float64
+
+	// Deterministic is whether the same input always encrypts the same
+	// way.
+	Deterministic           bool
+	DeterministicConfidence float64
+
+	// Padded is whether the input is padded to whole blocks.
+	Padded           bool
+	PaddedConfidence float64
+
+	// PrefixLen and SuffixLen are how many bytes of the output come
+	// from before and after the input, which includes any IV or nonce
+	// that the oracle outputs. They're -1 if the oracle isn't
+	// deterministic, since they can only be measured by comparing
+	// outputs.
+	PrefixLen           int
+	PrefixLenConfidence float64
+	SuffixLen           int
+	SuffixLenConfidence float64
+
+	Mode           Mode
+	ModeConfidence float64
+}
+
+// Limits for ProfileOracle.
+const (
+	profileMaxBlockSize = 128
+	profileRepeats      = 4
+	profileModeSamples  = 8
+	profilePrefixProbes = 4
+)
+
+// ProfileOracle works out as much as it can about an encryption
+// oracle by querying it with chosen inputs.
+//
+// The block size is the greatest common divisor of the changes in
+// output length as the input grows, so padded block modes show their
+// block size and stream modes show 1. The prefix length is found by
+// growing a run of fixed bytes until a change after it stops
+// affecting the block that the input starts in, and whatever output
+// isn't prefix or input is suffix. The mode comes from DetectMode,
+// asked several times in case the oracle changes its mind.
+func ProfileOracle(oracle EncryptOracle) (*OracleProfile, error) {
+	var (
+		p      = &OracleProfile{PrefixLen: -1, SuffixLen: -1}
+		failed error
+	)
+	// Errors are checked once each step is done.
+	encrypt := func(pt []byte) []byte {
+		if failed != nil {
+			return nil
+		}
+		ct, err := oracle.Encrypt(pt)
+		if err != nil {
+			failed = err
+		}
+		return ct
+	}
+
+	// Determinism.
+	var (
+		probe = bytes.Repeat([]byte{'A'}, 3*profileMaxBlockSize)
+		first = encrypt(probe)
+	)
+	p.Deterministic = true
+	for i := 1; i < profileRepeats; i++ {
+		if !bytes.Equal(encrypt(probe), first) {
+			p.Deterministic = false
+			break
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	if p.Deterministic {
+		// Any random choice with two or more equally likely outcomes
+		// would have shown up by now with this probability.
+		p.DeterministicConfidence = 1 - 1/float64(int(1)<<(profileRepeats-1))
+	} else {
+		p.DeterministicConfidence = 1
+	}
+
+	// Block size, from the output lengths.
+	var (
+		lens  = make([]int, 2*profileMaxBlockSize+2)
+		bs    int
+		jumps int
+	)
+	for n := range lens {
+		lens[n] = len(encrypt(bytes.Repeat([]byte{'A'}, n)))
+		if n > 0 && lens[n] != lens[n-1] {
+			jumps++
+		}
+		if d := lens[n] - lens[0]; d != 0 {
+			bs = gcd(bs, abs(d))
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	if bs == 0 {
+		return nil, fmt.Errorf("output length doesn't depend on input")
+	}
+	p.BlockSize = bs
+	p.BlockSizeConfidence = float64(jumps) / float64(jumps+1)
+	p.Padded = bs > 1
+	p.PaddedConfidence = p.BlockSizeConfidence
+
+	// Mode.
+	if bs == 1 {
+		p.Mode = ModeStream
+		p.ModeConfidence = p.BlockSizeConfidence
+	} else {
+		var ecb int
+		for i := 0; i < profileModeSamples; i++ {
+			m, err := DetectMode(oracle, bs)
+			if err != nil {
+				return nil, err
+			}
+			if m == ModeECB {
+				ecb++
+			}
+		}
+		if 2*ecb > profileModeSamples {
+			p.Mode = ModeECB
+			p.ModeConfidence = float64(ecb) / profileModeSamples
+		} else {
+			p.Mode = ModeCBC
+			p.ModeConfidence = float64(profileModeSamples-ecb) / profileModeSamples
+		}
+	}
+
+	if !p.Deterministic {
+		return p, nil
+	}
+
+	// Prefix and suffix lengths. The prefix is measured with a few
+	// different pairs of bytes, which should all agree.
+	var (
+		prefix int
+		votes  = make(map[int]int)
+	)
+	for i := 0; i < profilePrefixProbes; i++ {
+		n, err := oraclePrefixLen(oracle, bs, 'A'+2*byte(i), 'B'+2*byte(i))
+		if err != nil {
+			return nil, err
+		}
+		votes[n]++
+		if votes[n] > votes[prefix] {
+			prefix = n
+		}
+	}
+	prefixConfidence := float64(votes[prefix]) / profilePrefixProbes
+	// The input's length when the output first grows is the amount
+	// that pads the rest to a whole number of blocks, plus one more
+	// full block of PKCS#7 padding, unless there's no padding at all.
+	overhead := lens[0]
+	if bs > 1 {
+		for n := 1; n < len(lens); n++ {
+			if lens[n] > lens[0] {
+				overhead = lens[0] - n
+				break
+			}
+		}
+	}
+	// The suffix is whatever the prefix leaves, so it's exactly as
+	// certain.
+	p.PrefixLen, p.PrefixLenConfidence = prefix, prefixConfidence
+	p.SuffixLen, p.SuffixLenConfidence = overhead-prefix, prefixConfidence
+	if p.SuffixLen < 0 {
+		return nil, fmt.Errorf("inconsistent prefix and suffix lengths")
+	}
+	return p, nil
+}
+
+// oraclePrefixLen returns how many bytes of a deterministic oracle's
+// output come before its input. Two inputs that first differ after k
+// bytes encrypt the same up to the block that byte falls in, so the
+// first block that ever differs is where the input starts, and the
+// smallest k that leaves that block alone says where in the block.
+// The inputs are made of the bytes x and y.
+func oraclePrefixLen(oracle EncryptOracle, bs int, x, y byte) (int, error) {
+	a, err := oracle.Encrypt([]byte{x})
+	if err != nil {
+		return 0, err
+	}
+	b, err := oracle.Encrypt([]byte{y})
+	if err != nil {
+		return 0, err
+	}
+
+	q := -1
+	for i := 0; i+bs <= len(a) && i+bs <= len(b); i += bs {
+		if !bytes.Equal(a[i:i+bs], b[i:i+bs]) {
+			q = i / bs
+			break
+		}
+	}
+	if q < 0 {
+		return 0, fmt.Errorf("input doesn't affect the output")
+	}
+
+	for k := 1; k <= bs; k++ {
+		same := bytes.Repeat([]byte{x}, k)
+		a, err := oracle.Encrypt(append(same, x))
+		if err != nil {
+			return 0, err
+		}
+		b, err := oracle.Encrypt(append(same, y))
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(a[q*bs:(q+1)*bs], b[q*bs:(q+1)*bs]) {
+			return (q+1)*bs - k, nil
+		}
+	}
+	return 0, fmt.Errorf("prefix length not found")
+}
+
+// DetectMode asks an oracle with the given block size to encrypt
+// three blocks of identical bytes, which always contain two whole
+// identical blocks. Only ECB encrypts those the same way. It can't
+// tell CBC apart from other modes that pad and chain blocks.
+func DetectMode(oracle EncryptOracle, bs int) (Mode, error) {
+	if bs == 1 {
+		return ModeStream, nil
+	}
+	ct, err := oracle.Encrypt(make([]byte, 3*bs))
+	if err != nil {
+		return ModeUnknown, err
+	}
+	if IsECB(ct, bs) {
+		return ModeECB, nil
+	}
+	return ModeCBC, nil
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// abs returns the absolute value of a.
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,190 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProfileOracle(t *testing.T) {
+	t.Parallel()
+	var (
+		key    = bytes.Repeat([]byte{2}, 16)
+		iv     = bytes.Repeat([]byte{3}, 16)
+		prefix = []byte("userid=")
+		suffix = []byte(";comment2=%20like%20a%20pound%20of%20bacon")
+	)
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrap := func(in []byte) []byte {
+		var res []byte
+		res = append(res, prefix...)
+		res = append(res, in...)
+		return append(res, suffix...)
+	}
+
+	ecb, err := NewECBAppendOracle(suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random, err := NewECBOrCBCOracle()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		oracle EncryptOracle
+		want   OracleProfile
+	}{
+		"ECB append": {
+			oracle: ecb,
+			want: OracleProfile{
+				BlockSize: 16, Deterministic: true, Padded: true,
+				PrefixLen: 0, SuffixLen: len(suffix), Mode: ModeECB,
+			},
+		},
+		"CBC with fixed IV": {
+			oracle: EncryptOracleFunc(func(in []byte) ([]byte, error) {
+				pt := PKCS7Pad(wrap(in), 16)
+				cipher.NewCBCEncrypter(b, iv).CryptBlocks(pt, pt)
+				return pt, nil
+			}),
+			want: OracleProfile{
+				BlockSize: 16, Deterministic: true, Padded: true,
+				PrefixLen: len(prefix), SuffixLen: len(suffix), Mode: ModeCBC,
+			},
+		},
+		"CTR with nonce": {
+			oracle: EncryptOracleFunc(func(in []byte) ([]byte, error) {
+				pt := wrap(in)
+				cipher.NewCTR(b, iv).XORKeyStream(pt, pt)
+				return append(append([]byte(nil), iv...), pt...), nil
+			}),
+			want: OracleProfile{
+				BlockSize: 1, Deterministic: true, Padded: false,
+				PrefixLen: 16 + len(prefix), SuffixLen: len(suffix), Mode: ModeStream,
+			},
+		},
+		"ECB or CBC": {
+			oracle: random,
+			want: OracleProfile{
+				BlockSize: 16, Deterministic: false, Padded: true,
+				PrefixLen: -1, SuffixLen: -1,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		got, err := ProfileOracle(tc.oracle)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		// The random oracle's mode changes with every query.
+		if tc.want.Mode == ModeUnknown {
+			tc.want.Mode = got.Mode
+		}
+		if got.BlockSize != tc.want.BlockSize ||
+			got.Deterministic != tc.want.Deterministic ||
+			got.Padded != tc.want.Padded ||
+			got.PrefixLen != tc.want.PrefixLen ||
+			got.SuffixLen != tc.want.SuffixLen ||
+			got.Mode != tc.want.Mode {
+			t.Errorf("%s: got %+v, want %+v", name, *got, tc.want)
+		}
+		cs := []float64{
+			got.BlockSizeConfidence, got.DeterministicConfidence,
+			got.PaddedConfidence, got.ModeConfidence,
+		}
+		if got.Deterministic {
+			// Every prefix measurement should agree.
+			if got.PrefixLenConfidence != 1 || got.SuffixLenConfidence != 1 {
+				t.Errorf("%s: prefix measurements disagree in %+v", name, *got)
+			}
+		}
+		for _, c := range cs {
+			if c < 0.5 || c > 1 {
+				t.Errorf("%s: unusual confidence in %+v", name, *got)
+				break
+			}
+		}
+	}
+}
+
+func TestOracleMeter(t *testing.T) {
+	t.Parallel()
+	oracle, err := NewECBAppendOracle([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewECDSABiasedSigner(Curve59(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every oracle counts towards the same budget.
+	m := &OracleMeter{Budget: 3}
+	var (
+		enc  = m.Encrypt(oracle)
+		sign = m.Sign(signer)
+		msg  = []byte("hi mom")
+	)
+	if _, err := enc.Encrypt(msg); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := sign.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := sign.Verify(msg, sig); err != nil || !ok {
+		t.Fatalf("got %v, %v, want a valid signature", ok, err)
+	}
+
+	_, err = enc.Encrypt(msg)
+	var budget *BudgetError
+	if !errors.As(err, &budget) || budget.Budget != 3 {
+		t.Errorf("got %v, want a budget error", err)
+	}
+	if m.Queries() != 3 {
+		t.Errorf("got %d queries, want 3", m.Queries())
+	}
+
+	// The attacks pass budget errors on.
+	m = &OracleMeter{Budget: 10}
+	if _, err := ECBAppendRecoverSuffix(m.Encrypt(oracle)); !errors.As(err, &budget) {
+		t.Errorf("got %v, want a budget error", err)
+	}
+}
+
+func TestOracleMeter_Delays(t *testing.T) {
+	t.Parallel()
+	var (
+		m = &OracleMeter{
+			Latency:  5 * time.Millisecond,
+			Interval: 20 * time.Millisecond,
+		}
+		valid = m.Validity(ValidityOracleFunc(func([]byte) (bool, error) {
+			return true, nil
+		}))
+		start = time.Now()
+	)
+	for i := 0; i < 4; i++ {
+		if _, err := valid.Valid(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Three intervals between the four queries, and the latency of the
+	// last one.
+	if d, want := time.Since(start), 65*time.Millisecond; d < want {
+		t.Errorf("took %v, want at least %v", d, want)
+	}
+	if m.Queries() != 4 {
+		t.Errorf("got %d queries, want 4", m.Queries())
+	}
+}
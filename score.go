@@ -0,0 +1,240 @@
+package cryptopals
+
+import (
+	"bufio"
+	"io"
+	"math"
+)
+
+// Scorer scores how much a candidate plaintext looks like the real
+// thing. Higher is better.
+type Scorer interface {
+	Score(b []byte) float64
+}
+
+// ScorerFunc adapts a function to a Scorer.
+type ScorerFunc func(b []byte) float64
+
+// Score returns f(b).
+func (f ScorerFunc) Score(b []byte) float64 {
+	return f(b)
+}
+
+// DefaultScorer is the scorer the attacks use when given a nil Scorer.
+// It scores with Englishness.
+var DefaultScorer Scorer = ScorerFunc(Englishness)
+
+// scorerOrDefault returns s, or DefaultScorer if s is nil.
+func scorerOrDefault(s Scorer) Scorer {
+	if s == nil {
+		return DefaultScorer
+	}
+	return s
+}
+
+// englishLetterFreqs are the frequencies of the letters a to z in
+// English text.
+var englishLetterFreqs = [26]float64{
+	0.08167, 0.01492, 0.02782, 0.04253, 0.12702, 0.02228, 0.02015,
+	0.06094, 0.06966, 0.00153, 0.00772, 0.04025, 0.02406, 0.06749,
+	0.07507, 0.01929, 0.00095, 0.05987, 0.06327, 0.09056, 0.02758,
+	0.00978, 0.02360, 0.00150, 0.01974, 0.00074,
+}
+
+// Proportions of letters, spaces, other printable characters and
+// everything else in English text. Words average 4.79 letters (Peter
+// Norvig, "English Letter Frequency Counts: Mayzner Revisited"), so
+// with one space per word and 5% left for everything else, letters
+// are 0.95*4.79/5.79 of the text and spaces are 0.95/5.79.
+const (
+	englishLetters      = 0.786
+	englishSpaces       = 0.164
+	englishPrintable    = 0.0499
+	englishNonPrintable = 0.0001
+)
+
+// isPrintable reports whether c is printable ASCII or common
+// whitespace.
+func isPrintable(c byte) bool {
+	return c >= 0x20 && c < 0x7f || c == '\n' || c == '\r' || c == '\t'
+}
+
+// toLowerLetter returns the index of c in the alphabet, ignoring case,
+// or -1 if c isn't a letter.
+func toLowerLetter(c byte) int {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A')
+	}
+	return -1
+}
+
+// ChiSquaredScorer scores a text with the chi-squared test against
+// English letter frequencies, ignoring case. Spaces, other printable
+// characters and non-printable bytes each get their own category,
+// so the last are heavily penalized. The score is the negated
+// statistic.
+type ChiSquaredScorer struct{}
+
+// Score returns the negated chi-squared statistic of b.
+func (ChiSquaredScorer) Score(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var (
+		letters                         [26]int
+		spaces, printable, nonPrintable int
+	)
+	for _, c := range b {
+		switch l := toLowerLetter(c); {
+		case l >= 0:
+			letters[l]++
+		case c == ' ':
+			spaces++
+		case isPrintable(c):
+			printable++
+		default:
+			nonPrintable++
+		}
+	}
+
+	var (
+		n    = float64(len(b))
+		chi2 float64
+	)
+	add := func(observed int, p float64) {
+		e := n * p
+		d := float64(observed) - e
+		chi2 += d * d / e
+	}
+	for i, f := range englishLetterFreqs {
+		add(letters[i], englishLetters*f)
+	}
+	add(spaces, englishSpaces)
+	add(printable, englishPrintable)
+	add(nonPrintable, englishNonPrintable)
+	return -chi2
+}
+
+// englishBigramFreqs are the frequencies of the most common letter
+// pairs in English text.
+var englishBigramFreqs = map[string]float64{
+	"th": 0.0356, "he": 0.0307, "in": 0.0243, "er": 0.0205, "an": 0.0199,
+	"re": 0.0185, "on": 0.0176, "at": 0.0149, "en": 0.0145, "nd": 0.0135,
+	"ti": 0.0134, "es": 0.0134, "or": 0.0128, "te": 0.0120, "of": 0.0117,
+	"ed": 0.0117, "is": 0.0113, "it": 0.0112, "al": 0.0109, "ar": 0.0107,
+	"st": 0.0105, "to": 0.0104, "nt": 0.0104, "ng": 0.0095, "se": 0.0093,
+	"ha": 0.0093, "as": 0.0087, "ou": 0.0087, "io": 0.0083, "le": 0.0083,
+	"ve": 0.0083, "co": 0.0079, "me": 0.0079, "de": 0.0076, "hi": 0.0076,
+	"ri": 0.0073, "ro": 0.0073, "ic": 0.0070, "ne": 0.0069, "ea": 0.0069,
+	"ra": 0.0069, "ce": 0.0065, "li": 0.0062, "ch": 0.0060, "ll": 0.0058,
+	"be": 0.0058, "ma": 0.0057, "si": 0.0055, "om": 0.0055, "ur": 0.0054,
+}
+
+// Log-probabilities for pairs that aren't in englishBigramFreqs.
+var (
+	bigramRareLetters  = math.Log(0.0005)
+	bigramWordBoundary = math.Log(0.02)
+	bigramPrintable    = math.Log(0.002)
+	bigramNonPrintable = math.Log(1e-6)
+)
+
+// BigramScorer scores a text by the log-likelihood of each pair of
+// adjacent characters, ignoring case. Common English letter pairs
+// score best, then pairs of a letter and a space, then other letter
+// pairs and printable characters, and pairs with a non-printable byte
+// score worst.
+type BigramScorer struct{}
+
+// Score returns the log-likelihood of the pairs in b.
+func (BigramScorer) Score(b []byte) float64 {
+	var score float64
+	for i := 0; i+1 < len(b); i++ {
+		x, y := b[i], b[i+1]
+		var (
+			lx = toLowerLetter(x)
+			ly = toLowerLetter(y)
+		)
+		switch {
+		case !isPrintable(x) || !isPrintable(y):
+			score += bigramNonPrintable
+		case lx >= 0 && ly >= 0:
+			f, ok := englishBigramFreqs[string([]byte{'a' + byte(lx), 'a' + byte(ly)})]
+			if ok {
+				score += math.Log(f)
+			} else {
+				score += bigramRareLetters
+			}
+		case lx >= 0 && y == ' ', x == ' ' && ly >= 0:
+			score += bigramWordBoundary
+		default:
+			score += bigramPrintable
+		}
+	}
+	return score
+}
+
+// PrintableScorer scores a text by the fraction of its bytes that are
+// printable ASCII, so each control character or high byte is a
+// penalty. It can't tell apart plaintexts that only differ in case.
+type PrintableScorer struct{}
+
+// Score returns the fraction of printable bytes in b, or 0 if b is
+// empty.
+func (PrintableScorer) Score(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var count int
+	for _, c := range b {
+		if isPrintable(c) {
+			count++
+		}
+	}
+	return float64(count) / float64(len(b))
+}
+
+// CorpusScorer scores a text by the log-likelihood of its bytes under
+// the byte frequencies of a training corpus.
+type CorpusScorer struct {
+	logProbs [256]float64
+}
+
+// NewCorpusScorer returns a CorpusScorer trained on everything read
+// from r. Every byte value is counted once more than it appears, so
+// bytes missing from the corpus are unlikely, not impossible.
+func NewCorpusScorer(r io.Reader) (*CorpusScorer, error) {
+	var (
+		counts [256]float64
+		total  float64
+		br     = bufio.NewReader(r)
+	)
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[c]++
+		total++
+	}
+
+	s := new(CorpusScorer)
+	for i, c := range counts {
+		s.logProbs[i] = math.Log((c + 1) / (total + 256))
+	}
+	return s, nil
+}
+
+// Score returns the log-likelihood of b.
+func (s *CorpusScorer) Score(b []byte) float64 {
+	var score float64
+	for _, c := range b {
+		score += s.logProbs[c]
+	}
+	return score
+}
@@ -0,0 +1,77 @@
+package cryptopals
+
+import (
+	"strings"
+	"testing"
+)
+
+// scoreCorpus is a little English text to train on.
+const scoreCorpus = `It is a truth universally acknowledged, that a single man in
+possession of a good fortune, must be in want of a wife. However little known
+the feelings or views of such a man may be on his first entering a
+neighbourhood, this truth is so well fixed in the minds of the surrounding
+families, that he is considered as the rightful property of some one or other
+of their daughters. My dear Mr. Bennet, said his lady to him one day, have you
+heard that Netherfield Park is let at last? Mr. Bennet replied that he had not.`
+
+func TestScorers(t *testing.T) {
+	t.Parallel()
+	corpus, err := NewCorpusScorer(strings.NewReader(strings.ToUpper(scoreCorpus)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Englishness only counts ' ', 'e', 't' and 'a', none of which
+	// are in this uppercase plaintext, so it prefers a wrong key that
+	// turns it into lowercase t's and a's.
+	var (
+		pt       = []byte("ATTACK AT DAWN")
+		key byte = 0x5a
+		ct       = XORByte(pt, key)
+	)
+	got, err := SingleXORFindKey(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == key {
+		t.Errorf("Englishness: got key %#x, want it to pick a wrong one", got)
+	}
+
+	for name, s := range map[string]Scorer{
+		"chi-squared": ChiSquaredScorer{},
+		"bigram":      BigramScorer{},
+		"corpus":      corpus,
+	} {
+		got, err := SingleXORFindKeyWithScorer(ct, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != key {
+			t.Errorf("%s: got key %#x (%q), want %#x", name, got, XORByte(ct, got), key)
+		}
+	}
+}
+
+func TestScorers_Order(t *testing.T) {
+	t.Parallel()
+	corpus, err := NewCorpusScorer(strings.NewReader(scoreCorpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		english = []byte("the quick brown fox jumps over the lazy dog")
+		garbage = XORByte(english, 0x17)
+	)
+	for name, s := range map[string]Scorer{
+		"default":     nil,
+		"chi-squared": ChiSquaredScorer{},
+		"bigram":      BigramScorer{},
+		"printable":   PrintableScorer{},
+		"corpus":      corpus,
+	} {
+		s = scorerOrDefault(s)
+		if s.Score(english) <= s.Score(garbage) {
+			t.Errorf("%s: English scored %v, garbage scored %v", name, s.Score(english), s.Score(garbage))
+		}
+	}
+}
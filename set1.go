@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
+	"sort"
 )
 
 // HexToBase64 converts hex strings to Base64 strings.
@@ -41,28 +42,50 @@ func XORByte(a []byte, b byte) []byte {
 	return res
 }
 
-// SingleXORFindKey recovers the key from a single-byte-XOR
-// encrypted ciphertext.
-func SingleXORFindKey(ct []byte) (byte, error) {
+// SingleXORCandidate is a possible key for a single-byte-XOR
+// encrypted ciphertext, with its plaintext and score.
+type SingleXORCandidate struct {
+	Key   byte
+	PT    []byte
+	Score float64
+}
+
+// SingleXORCandidates returns all 256 possible keys for a
+// single-byte-XOR encrypted ciphertext, sorted by how well s scores
+// their plaintexts, best first. Keys that score the same stay in
+// ascending order. If s is nil, it uses DefaultScorer.
+func SingleXORCandidates(ct []byte, s Scorer) ([]SingleXORCandidate, error) {
 	if len(ct) == 0 {
-		return 0, fmt.Errorf("empty ciphertext")
+		return nil, fmt.Errorf("empty ciphertext")
 	}
 
-	var (
-		bestScore = -math.MaxFloat64 // higher is better
-		bestK     int
-	)
-
-	for k := 0; k < 256; k++ {
+	s = scorerOrDefault(s)
+	res := make([]SingleXORCandidate, 256)
+	for k := range res {
 		pt := XORByte(ct, byte(k))
-		score := Englishness(pt)
-		if score > bestScore {
-			bestScore = score
-			bestK = k
-		}
+		res[k] = SingleXORCandidate{Key: byte(k), PT: pt, Score: s.Score(pt)}
 	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	return res, nil
+}
 
-	return byte(bestK), nil
+// SingleXORFindKey recovers the key from a single-byte-XOR
+// encrypted ciphertext. It scores candidates with DefaultScorer.
+func SingleXORFindKey(ct []byte) (byte, error) {
+	return SingleXORFindKeyWithScorer(ct, nil)
+}
+
+// SingleXORFindKeyWithScorer is like SingleXORFindKey, but picks
+// the key whose plaintext s scores highest, or uses DefaultScorer
+// if s is nil.
+func SingleXORFindKeyWithScorer(ct []byte, s Scorer) (byte, error) {
+	cands, err := SingleXORCandidates(ct, s)
+	if err != nil {
+		return 0, err
+	}
+	return cands[0].Key, nil
 }
 
 // Englishness returns a score representing how English-like
@@ -109,33 +132,56 @@ func Entropy(b []byte) float64 {
 }
 
 // SingleXORDetect chooses a likely single-XOR-encrypted
-// ciphertext from multiple ciphertexts. It selects
-// the ciphertext with the lowest entropy.
+// ciphertext from multiple ciphertexts. It scores candidates
+// with DefaultScorer.
 func SingleXORDetect(cts [][]byte) ([]byte, error) {
+	return SingleXORDetectWithScorer(cts, nil)
+}
+
+// SingleXORDetectWithScorer is like SingleXORDetect, but selects
+// the ciphertext whose best candidate plaintext s scores highest,
+// or uses DefaultScorer if s is nil.
+func SingleXORDetectWithScorer(cts [][]byte, s Scorer) ([]byte, error) {
 	if len(cts) == 0 {
 		return nil, fmt.Errorf("empty ciphertexts")
 	}
 
 	var (
-		bestEntropy = math.MaxFloat64 // lower is better
-		bestCT      []byte
+		bestScore = math.Inf(-1) // higher is better
+		bestCT    []byte
 	)
 
 	for _, ct := range cts {
-		e := Entropy(ct)
-		if e < bestEntropy {
-			bestEntropy = e
+		if len(ct) == 0 {
+			continue
+		}
+		cands, err := SingleXORCandidates(ct, s)
+		if err != nil {
+			return nil, err
+		}
+		if bestCT == nil || cands[0].Score > bestScore {
+			bestScore = cands[0].Score
 			bestCT = ct
 		}
 	}
+	if bestCT == nil {
+		return nil, fmt.Errorf("empty ciphertexts")
+	}
 
 	return bestCT, nil
 }
 
 // SingleXORFindPT attacks single-XOR encryption
-// and returns a likely plaintext.
+// and returns a likely plaintext. It scores candidates
+// the same way as SingleXORFindKey.
 func SingleXORFindPT(ct []byte) ([]byte, error) {
-	key, err := SingleXORFindKey(ct)
+	return SingleXORFindPTWithScorer(ct, nil)
+}
+
+// SingleXORFindPTWithScorer is like SingleXORFindPT, but scores
+// candidates the same way as SingleXORFindKeyWithScorer.
+func SingleXORFindPTWithScorer(ct []byte, s Scorer) ([]byte, error) {
+	key, err := SingleXORFindKeyWithScorer(ct, s)
 	if err != nil {
 		return nil, err
 	}
@@ -166,82 +212,328 @@ func Hamming(a, b []byte) (int, error) {
 	return res, nil
 }
 
+// repeatingXORBeamWidth is how many candidates RepeatingXORFindKey
+// keeps for each byte of the key, and how many partial keys it keeps
+// after each step.
+const repeatingXORBeamWidth = 4
+
+// repeatingXORKeySizeTries is how many of the best key sizes
+// RepeatingXORFindKey tries.
+const repeatingXORKeySizeTries = 3
+
 // RepeatingXORFindKey attacks repeating-XOR encryption
-// and returns a likely key.
+// and returns a likely key. It scores candidates with
+// DefaultScorer.
 func RepeatingXORFindKey(ct []byte) ([]byte, error) {
-	keySize, err := RepeatingXORFindKeySize(ct, 2, 40)
+	return RepeatingXORFindKeyWithScorer(ct, nil)
+}
+
+// RepeatingXORFindKeyWithScorer is like RepeatingXORFindKey. It
+// finds a key for each of the best few sizes from
+// RepeatingXORKeySizesHamming, and keeps the one whose decryption
+// s scores highest. It scores candidates the same way as
+// SingleXORFindKeyWithScorer.
+func RepeatingXORFindKeyWithScorer(ct []byte, s Scorer) ([]byte, error) {
+	sizes, err := RepeatingXORKeySizesHamming(ct, 2, 40)
 	if err != nil {
 		return nil, err
 	}
-	chunkSize := (len(ct) + keySize - 1) / keySize
+	if len(sizes) > repeatingXORKeySizeTries {
+		sizes = sizes[:repeatingXORKeySizeTries]
+	}
 
 	var (
-		key   = make([]byte, keySize)
-		chunk = make([]byte, chunkSize)
+		bestKey   []byte
+		bestScore = math.Inf(-1)
 	)
+	s = scorerOrDefault(s)
+	for _, size := range sizes {
+		key, err := repeatingXORBeamSearch(ct, size.Size, s, repeatingXORBeamWidth)
+		if err != nil {
+			return nil, err
+		}
+		key = shortestPeriod(key)
+		if score := s.Score(RepeatingXOR(ct, key)); bestKey == nil || score > bestScore {
+			bestKey, bestScore = key, score
+		}
+	}
+
+	return bestKey, nil
+}
 
-	for i := range key {
-		// Read the chunk.
-		for j := range chunk {
-			k := j*keySize + i
-			if k < len(ct) {
-				chunk[j] = ct[k]
+// shortestPeriod returns the shortest prefix of key that repeats to
+// make key.
+func shortestPeriod(key []byte) []byte {
+	for p := 1; p < len(key); p++ {
+		if len(key)%p != 0 {
+			continue
+		}
+		repeats := true
+		for i := p; i < len(key); i++ {
+			if key[i] != key[i-p] {
+				repeats = false
+				break
 			}
 		}
+		if repeats {
+			return key[:p]
+		}
+	}
+	return key
+}
 
-		// Find one byte of key material.
-		key[i], err = SingleXORFindKey(chunk)
+// repeatingXORBeamSearch finds a key one byte at a time. The
+// candidates for each byte are the best few for its column of the
+// ciphertext, and after each byte, it keeps the partial keys whose
+// decryptions of the start of every block score best together. That
+// lets scorers that look at neighbouring bytes correct the columns.
+func repeatingXORBeamSearch(ct []byte, keySize int, s Scorer, width int) ([]byte, error) {
+	type partial struct {
+		key   []byte
+		score float64
+	}
+
+	s = scorerOrDefault(s)
+	beam := []partial{{}}
+	for i := 0; i < keySize; i++ {
+		var column []byte
+		for j := i; j < len(ct); j += keySize {
+			column = append(column, ct[j])
+		}
+		cands, err := SingleXORCandidates(column, s)
 		if err != nil {
 			return nil, err
 		}
+		if len(cands) > width {
+			cands = cands[:width]
+		}
+
+		var next []partial
+		for _, p := range beam {
+			for _, c := range cands {
+				key := append(append([]byte(nil), p.key...), c.Key)
+				next = append(next, partial{key: key, score: s.Score(repeatingXORPrefixes(ct, key, keySize))})
+			}
+		}
+		sort.SliceStable(next, func(i, j int) bool {
+			return next[i].score > next[j].score
+		})
+		if len(next) > width {
+			next = next[:width]
+		}
+		beam = next
 	}
 
-	return key, nil
+	return beam[0].key, nil
 }
 
-// RepeatingXORFindKeySize attacks repeating-XOR encryption
-// and returns a likely key size. It checks all key sizes between
-// a and b, inclusive.
-func RepeatingXORFindKeySize(ct []byte, a, b int) (int, error) {
-	if a > b || 8*b > len(ct) {
+// repeatingXORPrefixes decrypts the first len(key) bytes of every
+// keySize-byte block of ct, and returns them joined together.
+func repeatingXORPrefixes(ct, key []byte, keySize int) []byte {
+	var res []byte
+	for i := 0; i < len(ct); i += keySize {
+		for j := 0; j < len(key) && i+j < len(ct); j++ {
+			res = append(res, ct[i+j]^key[j])
+		}
+	}
+	return res
+}
+
+// KeySizeCandidate is a possible repeating-XOR key size and its
+// score. Higher scores are better.
+type KeySizeCandidate struct {
+	Size  int
+	Score float64
+}
+
+// keySizeRange checks that key sizes from a to b are sensible, and
+// lowers b until ct has at least two blocks of each size.
+func keySizeRange(ct []byte, a, b int) (int, error) {
+	if b > len(ct)/2 {
+		b = len(ct) / 2
+	}
+	if a < 1 || a > b {
 		return 0, fmt.Errorf("invalid range")
 	}
+	return b, nil
+}
 
-	var (
-		bestSize  int
-		bestScore = math.MaxFloat64
-	)
+// sortKeySizes sorts candidates best first. Sizes that score the
+// same stay smallest first, since multiples of the key size tend to
+// score as well as the key size itself.
+func sortKeySizes(cands []KeySizeCandidate) {
+	sort.SliceStable(cands, func(i, j int) bool {
+		return cands[i].Score > cands[j].Score
+	})
+}
 
+// repeatingXORHammingBlocks is how many blocks of each size
+// RepeatingXORKeySizesHamming compares at most, so the work doesn't
+// grow with the square of the ciphertext length.
+const repeatingXORHammingBlocks = 64
+
+// RepeatingXORKeySizesHamming ranks the key sizes between a and b
+// inclusive by the Hamming distance between blocks of each size,
+// normalized by the size and averaged over every pair of the first
+// 64 whole blocks. Blocks encrypted with the same key are closer
+// together, so the score is the negated average. Sizes larger than
+// half of ct are skipped.
+func RepeatingXORKeySizesHamming(ct []byte, a, b int) ([]KeySizeCandidate, error) {
+	b, err := keySizeRange(ct, a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []KeySizeCandidate
 	for n := a; n <= b; n++ {
-		// Challenge 6 recommends taking multiple blocks.
-		x, y := ct[:n*4], ct[n*4:n*8]
-		h, err := Hamming(x, y)
-		if err != nil {
-			return 0, err
+		var (
+			blocks = len(ct) / n
+			total  float64
+		)
+		if blocks > repeatingXORHammingBlocks {
+			blocks = repeatingXORHammingBlocks
 		}
+		for i := 0; i < blocks; i++ {
+			for j := i + 1; j < blocks; j++ {
+				h, err := Hamming(ct[i*n:(i+1)*n], ct[j*n:(j+1)*n])
+				if err != nil {
+					return nil, err
+				}
+				total += float64(h)
+			}
+		}
+		pairs := blocks * (blocks - 1) / 2
+		res = append(res, KeySizeCandidate{Size: n, Score: -total / float64(pairs*n)})
+	}
+	sortKeySizes(res)
+	return res, nil
+}
+
+// indexOfCoincidence returns the chance that two bytes picked from
+// different positions in b are equal. It returns 0 if b has fewer
+// than two bytes.
+func indexOfCoincidence(b []byte) float64 {
+	if len(b) < 2 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	var sum int
+	for _, c := range counts {
+		sum += c * (c - 1)
+	}
+	return float64(sum) / float64(len(b)*(len(b)-1))
+}
 
-		score := float64(h) / float64(n)
-		if score < bestScore {
-			bestSize = n
-			bestScore = score
+// RepeatingXORKeySizesIoC ranks the key sizes between a and b
+// inclusive by the average index of coincidence of the columns of
+// ct, where column i has the bytes encrypted with byte i of the key.
+// With the right size, each column is plaintext XORed with a single
+// byte, which keeps the plaintext's index of coincidence. The score
+// is the average. Sizes larger than half of ct are skipped.
+func RepeatingXORKeySizesIoC(ct []byte, a, b int) ([]KeySizeCandidate, error) {
+	b, err := keySizeRange(ct, a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []KeySizeCandidate
+	for n := a; n <= b; n++ {
+		var total float64
+		for i := 0; i < n; i++ {
+			var column []byte
+			for j := i; j < len(ct); j += n {
+				column = append(column, ct[j])
+			}
+			total += indexOfCoincidence(column)
 		}
+		res = append(res, KeySizeCandidate{Size: n, Score: total / float64(n)})
+	}
+	sortKeySizes(res)
+	return res, nil
+}
+
+// englishIoC is the index of coincidence of English text, and
+// randomIoC is that of uniformly random bytes.
+const (
+	englishIoC = 0.07
+	randomIoC  = 1.0 / 256
+)
+
+// RepeatingXORKeySizesFriedman ranks the key sizes between a and b
+// inclusive with the Friedman test. It estimates the key size from
+// how far the index of coincidence of ct has fallen from that of
+// English toward that of random bytes, and the score is the negated
+// distance from the estimate. It's cheap but rough, and it assumes
+// the key bytes are unrelated, as in a random key. Sizes larger than
+// half of ct are skipped.
+func RepeatingXORKeySizesFriedman(ct []byte, a, b int) ([]KeySizeCandidate, error) {
+	b, err := keySizeRange(ct, a, b)
+	if err != nil {
+		return nil, err
 	}
 
-	return bestSize, nil
+	var (
+		ioc      = indexOfCoincidence(ct)
+		estimate = math.Inf(1)
+	)
+	if ioc > randomIoC {
+		estimate = (englishIoC - randomIoC) / (ioc - randomIoC)
+	}
+
+	var res []KeySizeCandidate
+	for n := a; n <= b; n++ {
+		res = append(res, KeySizeCandidate{Size: n, Score: -math.Abs(float64(n) - estimate)})
+	}
+	sortKeySizes(res)
+	return res, nil
 }
 
-// ECBCipher represents an ECB mode cipher.
+// RepeatingXORFindKeySize attacks repeating-XOR encryption
+// and returns a likely key size. It checks all key sizes between
+// a and b, inclusive, and picks the best one from
+// RepeatingXORKeySizesHamming.
+func RepeatingXORFindKeySize(ct []byte, a, b int) (int, error) {
+	cands, err := RepeatingXORKeySizesHamming(ct, a, b)
+	if err != nil {
+		return 0, err
+	}
+	return cands[0].Size, nil
+}
+
+// ECBCipher represents an ECB mode cipher. It's also a
+// cipher.BlockMode that encrypts; NewECBDecrypter returns
+// one that decrypts.
 type ECBCipher struct {
 	b cipher.Block
 }
 
-// NewECBCipher returns a new ECBCipher.
+// NewECBCipher returns a new ECBCipher that uses AES.
 func NewECBCipher(key []byte) (*ECBCipher, error) {
 	b, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return &ECBCipher{b: b}, nil
+	return NewECBCipherFromBlock(b), nil
+}
+
+// NewECBCipherFromBlock returns a new ECBCipher that uses b.
+func NewECBCipherFromBlock(b cipher.Block) *ECBCipher {
+	return &ECBCipher{b: b}
+}
+
+// BlockSize returns the block size.
+func (e *ECBCipher) BlockSize() int {
+	return e.b.BlockSize()
+}
+
+// CryptBlocks encrypts src into dst, which may be src itself.
+// It panics if src isn't whole blocks, or if dst is shorter
+// than src.
+func (e *ECBCipher) CryptBlocks(dst, src []byte) {
+	NewECBEncrypter(e.b).CryptBlocks(dst, src)
 }
 
 // Encrypt encrypts a plaintext. It panics on failure.
@@ -250,9 +542,7 @@ func (e *ECBCipher) Encrypt(pt []byte) []byte {
 		panic("invalid ECB plaintext")
 	}
 	ct := make([]byte, len(pt))
-	for i := 0; i < len(pt); i += e.b.BlockSize() {
-		e.b.Encrypt(ct[i:], pt[i:])
-	}
+	e.CryptBlocks(ct, pt)
 	return ct
 }
 
@@ -262,9 +552,7 @@ func (e *ECBCipher) Decrypt(ct []byte) []byte {
 		panic("invalid ECB ciphertext")
 	}
 	pt := make([]byte, len(ct))
-	for i := 0; i < len(ct); i += e.b.BlockSize() {
-		e.b.Decrypt(pt[i:], ct[i:])
-	}
+	NewECBDecrypter(e.b).CryptBlocks(pt, ct)
 	return pt
 }
 
@@ -56,6 +56,35 @@ func TestChallenge3(t *testing.T) {
 	t.Logf("solve: %s", XORByte(ct, got))
 }
 
+func TestSingleXORCandidates(t *testing.T) {
+	t.Parallel()
+	ct := HelperDecodeHex(t, "1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736")
+
+	cands, err := SingleXORCandidates(ct, ChiSquaredScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 256 {
+		t.Fatalf("got %d candidates, want 256", len(cands))
+	}
+	seen := make(map[byte]bool)
+	for i, c := range cands {
+		if i > 0 && c.Score > cands[i-1].Score {
+			t.Errorf("candidate %d scores higher than candidate %d", i, i-1)
+		}
+		if !bytes.Equal(c.PT, XORByte(ct, c.Key)) {
+			t.Errorf("key %d has the wrong plaintext", c.Key)
+		}
+		seen[c.Key] = true
+	}
+	if len(seen) != 256 {
+		t.Errorf("got %d distinct keys, want 256", len(seen))
+	}
+	if cands[0].Key != 88 {
+		t.Errorf("got best key %d, want 88", cands[0].Key)
+	}
+}
+
 func TestChallenge4(t *testing.T) {
 	t.Parallel()
 	var (
@@ -132,6 +161,98 @@ func TestChallenge6(t *testing.T) {
 	t.Logf("solve: %s", RepeatingXOR(ct, got))
 }
 
+func TestRepeatingXORKeySizes(t *testing.T) {
+	t.Parallel()
+	var (
+		ct  = HelperReadFileBase64(t, "testdata/6.txt")
+		key = []byte("Terminator X: Bring the noise")
+	)
+
+	for name, f := range map[string]func([]byte, int, int) ([]KeySizeCandidate, error){
+		"hamming": RepeatingXORKeySizesHamming,
+		"ioc":     RepeatingXORKeySizesIoC,
+	} {
+		got, err := f(ct, 2, 40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 39 {
+			t.Errorf("%s: got %d candidates, want 39", name, len(got))
+		}
+		if got[0].Size != len(key) {
+			t.Errorf("%s: got best size %d, want %d", name, got[0].Size, len(key))
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Score > got[i-1].Score {
+				t.Errorf("%s: candidate %d scores higher than candidate %d", name, i, i-1)
+			}
+		}
+	}
+
+	// The Friedman test assumes the key bytes are unrelated, which
+	// random keys are, unlike ASCII ones. It gets less precise as the
+	// key gets longer.
+	var (
+		pt        = RepeatingXOR(ct, key)
+		randomKey = []byte{0x8e, 0x1f, 0xd2}
+	)
+	got, err := RepeatingXORKeySizesFriedman(RepeatingXOR(pt, randomKey), 2, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got[0].Size - len(randomKey); d < -1 || d > 1 {
+		t.Errorf("friedman: got best size %d, want %d±1", got[0].Size, len(randomKey))
+	}
+
+	// Only the first few blocks of each size are compared, so a
+	// megabyte of ciphertext takes no longer than a few kilobytes.
+	long := RepeatingXOR(bytes.Repeat(pt, 400), key)
+	got, err = RepeatingXORKeySizesHamming(long, 2, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Size != len(key) {
+		t.Errorf("hamming, long: got best size %d, want %d", got[0].Size, len(key))
+	}
+}
+
+func TestRepeatingXORFindKeySize_Short(t *testing.T) {
+	t.Parallel()
+	// Too short for four blocks of the largest sizes.
+	ct := RepeatingXOR([]byte("Burning 'em, if you ain't quick and nimble"), []byte("ICE"))
+
+	if _, err := RepeatingXORFindKeySize(ct, 2, 40); err != nil {
+		t.Error(err)
+	}
+	if _, err := RepeatingXORFindKeySize(ct, 30, 40); err == nil {
+		t.Error("no error for sizes with less than two blocks")
+	}
+	if _, err := RepeatingXORFindKeySize(ct, 0, 4); err == nil {
+		t.Error("no error for size 0")
+	}
+}
+
+func TestRepeatingXORFindKey_Scorers(t *testing.T) {
+	t.Parallel()
+	var (
+		ct   = HelperReadFileBase64(t, "testdata/6.txt")
+		want = []byte("Terminator X: Bring the noise")
+	)
+
+	for name, s := range map[string]Scorer{
+		"chi-squared": ChiSquaredScorer{},
+		"bigram":      BigramScorer{},
+	} {
+		got, err := RepeatingXORFindKeyWithScorer(ct, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
 func TestChallenge7(t *testing.T) {
 	t.Parallel()
 	var (
@@ -6,7 +6,6 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"fmt"
-	"log"
 	"math"
 	"net/url"
 )
@@ -31,7 +30,8 @@ func PKCS7Pad(b []byte, blockSize int) []byte {
 
 // PKCS7Unpad unpads a byte slice using PKCS#7. It doesn't check any
 // byte other than the last, so it's super insecure. That's not
-// important for any attack; I'm just lazy.
+// important for any attack; I'm just lazy. See PKCS7Validate for a
+// version that checks.
 // It panics on invalid input.
 func PKCS7Unpad(b []byte) []byte {
 	if len(b) == 0 {
@@ -46,18 +46,68 @@ func PKCS7Unpad(b []byte) []byte {
 	return b[:len(b)-int(pad)]
 }
 
-// CBCCipher represents a CBC mode cipher.
+// PKCS7Validate unpads a byte slice using PKCS#7, and fails if the
+// padding is invalid. Every pad byte is checked.
+func PKCS7Validate(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	pad := b[len(b)-1]
+	if pad == 0 || int(pad) > len(b) {
+		return nil, fmt.Errorf("invalid pad byte %x", pad)
+	}
+	for _, c := range b[len(b)-int(pad):] {
+		if c != pad {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
+
+	return b[:len(b)-int(pad)], nil
+}
+
+// CBCCipher represents a CBC mode cipher. Encrypt and Decrypt take
+// an IV for each message. As a cipher.BlockMode, it encrypts one
+// message in pieces, chaining from an IV set by SetIV.
 type CBCCipher struct {
-	b cipher.Block
+	b     cipher.Block
+	chain *cbcMode
 }
 
-// NewCBCCipher returns a new CBCCipher.
+// NewCBCCipher returns a new CBCCipher that uses AES.
 func NewCBCCipher(key []byte) (*CBCCipher, error) {
 	b, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return &CBCCipher{b: b}, nil
+	return NewCBCCipherFromBlock(b), nil
+}
+
+// NewCBCCipherFromBlock returns a new CBCCipher that uses b. Its
+// chain starts from an all-zero IV.
+func NewCBCCipherFromBlock(b cipher.Block) *CBCCipher {
+	return &CBCCipher{
+		b:     b,
+		chain: newCBCMode(b, make([]byte, b.BlockSize()), false),
+	}
+}
+
+// SetIV restarts the chain from iv. It panics if the IV isn't one
+// block long.
+func (c *CBCCipher) SetIV(iv []byte) {
+	c.chain = newCBCMode(c.b, iv, false)
+}
+
+// BlockSize returns the block size.
+func (c *CBCCipher) BlockSize() int {
+	return c.b.BlockSize()
+}
+
+// CryptBlocks encrypts src into dst, which may be src itself,
+// carrying on from the last block it encrypted. It panics if src
+// isn't whole blocks, or if dst is shorter than src.
+func (c *CBCCipher) CryptBlocks(dst, src []byte) {
+	c.chain.CryptBlocks(dst, src)
 }
 
 // Encrypt encrypts a plaintext using an IV.
@@ -68,22 +118,8 @@ func (c *CBCCipher) Encrypt(pt, iv []byte) ([]byte, error) {
 	if len(iv) != c.b.BlockSize() {
 		return nil, fmt.Errorf("invalid IV")
 	}
-
-	var (
-		ct   = make([]byte, len(pt))
-		prev = iv
-	)
-
-	for i := 0; i < len(pt); i += c.b.BlockSize() {
-		tmp, err := XORBytes(pt[i:i+c.b.BlockSize()], prev)
-		if err != nil {
-			return nil, err
-		}
-		copy(ct[i:], tmp)
-		c.b.Encrypt(ct[i:], ct[i:])
-		prev = ct[i : i+c.b.BlockSize()]
-	}
-
+	ct := make([]byte, len(pt))
+	NewCBCEncrypter(c.b, iv).CryptBlocks(ct, pt)
 	return ct, nil
 }
 
@@ -95,23 +131,8 @@ func (c *CBCCipher) Decrypt(ct, iv []byte) ([]byte, error) {
 	if len(iv) != c.b.BlockSize() {
 		return nil, fmt.Errorf("invalid IV")
 	}
-
-	var (
-		pt   = make([]byte, len(ct))
-		tmp  = make([]byte, c.b.BlockSize())
-		prev = iv
-	)
-
-	for i := 0; i < len(ct); i += c.b.BlockSize() {
-		c.b.Decrypt(tmp, ct[i:])
-		tmp, err := XORBytes(tmp, prev)
-		if err != nil {
-			return nil, err
-		}
-		copy(pt[i:], tmp)
-		prev = ct[i : i+c.b.BlockSize()]
-	}
-
+	pt := make([]byte, len(ct))
+	NewCBCDecrypter(c.b, iv).CryptBlocks(pt, ct)
 	return pt, nil
 }
 
@@ -220,65 +241,56 @@ func NewECBAppendOracle(suffix []byte) (*ECBAppendOracle, error) {
 	return &ECBAppendOracle{ecb: ecb, suffix: suffix}, nil
 }
 
-func (e ECBAppendOracle) Encrypt(b []byte) []byte {
+func (e ECBAppendOracle) Encrypt(b []byte) ([]byte, error) {
 	pt := PKCS7Pad(append(b, e.suffix...), 16)
-	return e.ecb.Encrypt(pt)
+	return e.ecb.Encrypt(pt), nil
 }
 
-func ECBAppendFindBlockSize(oracle *ECBAppendOracle) (int, error) {
-	for bs := 2; bs <= 128; bs++ {
-		pt := bytes.Repeat([]byte{0}, bs*2)
-		ct := oracle.Encrypt(pt)
-
-		if IsECB(ct[:bs*2], bs) {
-			return bs, nil
-		}
+// ECBAppendFindBlockSize returns the oracle's block size, using
+// ProfileOracle.
+func ECBAppendFindBlockSize(oracle EncryptOracle) (int, error) {
+	p, err := ProfileOracle(oracle)
+	if err != nil {
+		return 0, err
 	}
-	return 0, fmt.Errorf("block size not found")
+	return p.BlockSize, nil
 }
 
-func ECBAppendFindSuffixLen(oracle *ECBAppendOracle) (int, error) {
-	bs, err := ECBAppendFindBlockSize(oracle)
+// ECBAppendFindSuffixLen returns the length of the oracle's secret
+// suffix, using ProfileOracle.
+func ECBAppendFindSuffixLen(oracle EncryptOracle) (int, error) {
+	p, err := ProfileOracle(oracle)
 	if err != nil {
 		return 0, err
 	}
-	log.Println(bs)
-
-	ct := oracle.Encrypt([]byte{})
-	bound := len(ct)
-
-	for i := 1; i <= bs; i++ {
-		pt := bytes.Repeat([]byte{0}, i)
-		ct := oracle.Encrypt(pt)
-
-		if len(ct) > bound {
-			return bound - i, nil
-		}
-	}
-
-	return 0, fmt.Errorf("suffix length not found")
+	return p.SuffixLen, nil
 }
 
-func ECBAppendRecoverSuffix(oracle *ECBAppendOracle) ([]byte, error) {
-	bs, err := ECBAppendFindBlockSize(oracle)
+func ECBAppendRecoverSuffix(oracle EncryptOracle) ([]byte, error) {
+	p, err := ProfileOracle(oracle)
 	if err != nil {
 		return nil, err
 	}
-
-	suffixLen, err := ECBAppendFindSuffixLen(oracle)
-	if err != nil {
-		return nil, err
+	if p.Mode != ModeECB || p.PrefixLen != 0 {
+		return nil, fmt.Errorf("not an ECB oracle with no prefix")
 	}
+	bs, suffixLen := p.BlockSize, p.SuffixLen
 
 	var res []byte
 
 	for i := 0; i < suffixLen; i++ {
 		referencePT := bytes.Repeat([]byte{0}, bs-(len(res)%bs)-1)
-		referenceCT := oracle.Encrypt(referencePT)
+		referenceCT, err := oracle.Encrypt(referencePT)
+		if err != nil {
+			return nil, err
+		}
 
 		for j := 0; j < 256; j++ {
 			pt := append(append(referencePT, res...), byte(j))
-			ct := oracle.Encrypt(pt)
+			ct, err := oracle.Encrypt(pt)
+			if err != nil {
+				return nil, err
+			}
 
 			if bytes.Equal(ct[:len(pt)], referenceCT[:len(pt)]) {
 				res = append(res, byte(j))
@@ -319,11 +331,16 @@ func NewECBProfileManager() (*ECBProfileManager, error) {
 
 func (m ECBProfileManager) Profile(email string) string {
 	profile := ProfileFor(email)
-	log.Println(profile)
 	pt := PKCS7Pad([]byte(profile), 16)
 	return string(m.ecb.Encrypt(pt))
 }
 
+// Encrypt returns the encrypted profile for an email, so the manager
+// can be used as an EncryptOracle.
+func (m ECBProfileManager) Encrypt(email []byte) ([]byte, error) {
+	return []byte(m.Profile(string(email))), nil
+}
+
 func (m ECBProfileManager) IsAdmin(profile string) bool {
 	pt := m.ecb.Decrypt([]byte(profile))
 	pt = PKCS7Unpad(pt)
@@ -337,20 +354,26 @@ func (m ECBProfileManager) IsAdmin(profile string) bool {
 
 // NewAdminProfile performs a cut-and-paste ECB attack to
 // transform a user profile into an admin profile.
-func NewAdminProfile(m *ECBProfileManager) string {
+func NewAdminProfile(oracle EncryptOracle) (string, error) {
 	// Note that url.Values sorts alphabetically by key.
 
 	// |<------------>||<------------>|
 	// email=jeffy.b%40amazon.com&role=user&uid=10.....
-	left := m.Profile("jeffy.b@amazon.com")[:32]
+	left, err := oracle.Encrypt([]byte("jeffy.b@amazon.com"))
+	if err != nil {
+		return "", err
+	}
 
 	//                 |<------------>||<------------>|
 	// email=pizza%40x.admin&role=user&uid=10..........
-	right := m.Profile("pizza@x.admin")[16:]
+	right, err := oracle.Encrypt([]byte("pizza@x.admin"))
+	if err != nil {
+		return "", err
+	}
 
 	// |<------------>||<------------>||<------------>||<------------>|
 	// email=jeffy.b%40amazon.com&role=admin&role=user&uid=10..........
-	return left + right
+	return string(left[:32]) + string(right[16:]), nil
 }
 
 type ECBPrependAppendOracle struct {
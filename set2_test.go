@@ -2,7 +2,6 @@ package cryptopals
 
 import (
 	"bytes"
-	"math"
 	"strings"
 	"testing"
 )
@@ -56,6 +55,36 @@ func TestPKCS7Unpad(t *testing.T) {
 	}
 }
 
+func TestPKCS7Validate(t *testing.T) {
+	t.Parallel()
+	valid := map[string]string{
+		"ICE ICE BABY\x04\x04\x04\x04": "ICE ICE BABY",
+		"\x01":                         "",
+	}
+	for in, want := range valid {
+		got, err := PKCS7Validate([]byte(in))
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"ICE ICE BABY\x05\x05\x05\x05",
+		"ICE ICE BABY\x01\x02\x03\x04",
+		"ICE ICE BABY\x00",
+		"\x02",
+	} {
+		if _, err := PKCS7Validate([]byte(in)); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
 func TestECBCipher(t *testing.T) {
 	t.Parallel()
 	var (
@@ -122,28 +151,25 @@ func TestChallenge10(t *testing.T) {
 
 func TestChallenge11(t *testing.T) {
 	t.Parallel()
-	var (
-		pt     = bytes.Repeat([]byte{0}, 16*10)
-		trials = 1000
-	)
+	trials := 1000
 
 	oracle, err := NewECBOrCBCOracle()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	var ecb int
 	for i := 0; i < trials; i++ {
-		ct, err := oracle.Encrypt(pt)
+		m, err := DetectMode(oracle, 16)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if IsECB(ct, 16) {
+		if m == ModeECB {
 			ecb++
 		}
 	}
 
-	freq := math.Abs(float64(ecb) / float64(trials))
+	freq := float64(ecb) / float64(trials)
 	if freq < 0.4 || freq > 0.6 {
 		t.Errorf("unusual freq: %f", freq)
 	}
@@ -194,7 +220,10 @@ func TestChallenge13(t *testing.T) {
 		t.Error(err)
 	}
 
-	profile := NewAdminProfile(m)
+	profile, err := NewAdminProfile(m)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !m.IsAdmin(profile) {
 		t.Errorf("not admin profile: %x", profile)
 	}
@@ -0,0 +1,37 @@
+package cryptopals
+
+import "fmt"
+
+// FixedNonceCTRRecoverKeystream recovers the keystream shared by
+// ciphertexts encrypted with CTR under one key and nonce. Byte i of
+// the keystream is a single-byte XOR key for byte i of every
+// ciphertext, so each is found with SingleXORFindKeyWithScorer and
+// s. The keystream is as long as the longest ciphertext, but bytes
+// past the end of most ciphertexts are guesses from very few samples.
+func FixedNonceCTRRecoverKeystream(cts [][]byte, s Scorer) ([]byte, error) {
+	var n int
+	for _, ct := range cts {
+		if len(ct) > n {
+			n = len(ct)
+		}
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("empty ciphertexts")
+	}
+
+	ks := make([]byte, n)
+	for i := range ks {
+		var column []byte
+		for _, ct := range cts {
+			if i < len(ct) {
+				column = append(column, ct[i])
+			}
+		}
+		k, err := SingleXORFindKeyWithScorer(column, s)
+		if err != nil {
+			return nil, err
+		}
+		ks[i] = k
+	}
+	return ks, nil
+}
@@ -0,0 +1,76 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"strings"
+	"testing"
+)
+
+func TestChallenge20(t *testing.T) {
+	t.Parallel()
+	var (
+		key = []byte("YELLOW SUBMARINE")
+		pts = [][]byte{
+			[]byte("It was the best of times, it was the worst of times, it was the age of wisdom"),
+			[]byte("In a hole in the ground there lived a hobbit. Not a nasty, dirty, wet hole"),
+			[]byte("Call me Ishmael. Some years ago, never mind how long precisely, having little"),
+			[]byte("It is a truth universally acknowledged, that a single man in possession of a"),
+			[]byte("All happy families are alike; each unhappy family is unhappy in its own way."),
+			[]byte("The sky above the port was the color of television, tuned to a dead channel"),
+			[]byte("Happy families are all alike, but the ones in this story had nothing at all"),
+			[]byte("Far out in the uncharted backwaters of the unfashionable end of the western"),
+			[]byte("Whether I shall turn out to be the hero of my own life, or whether that place"),
+			[]byte("It was a bright cold day in April, and the clocks were striking thirteen. He"),
+			[]byte("Once upon a time there was a little girl who lived in a village near a forest"),
+			[]byte("We were somewhere around Barstow on the edge of the desert when the drugs began"),
+		}
+	)
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cts    [][]byte
+		minLen = len(pts[0])
+	)
+	for _, pt := range pts {
+		ct := make([]byte, len(pt))
+		cipher.NewCTR(b, make([]byte, 16)).XORKeyStream(ct, pt)
+		cts = append(cts, ct)
+		if len(pt) < minLen {
+			minLen = len(pt)
+		}
+	}
+
+	// Every column has a different mix of letters, so a scorer that
+	// knows about case and punctuation does best.
+	corpus, err := NewCorpusScorer(strings.NewReader(scoreCorpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := FixedNonceCTRRecoverKeystream(cts, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every first letter is a capital, and the corpus is mostly
+	// lowercase, so the first column can come back with its case
+	// flipped. Everything else must be exact.
+	for i, ct := range cts {
+		got, _ := XORBytes(ct[:minLen], ks[:minLen])
+		want := pts[i][:minLen]
+		if !bytes.EqualFold(got[:1], want[:1]) || !bytes.Equal(got[1:], want[1:]) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		t.Logf("solve: %s", got)
+	}
+}
+
+func TestFixedNonceCTRRecoverKeystream_Empty(t *testing.T) {
+	t.Parallel()
+	if _, err := FixedNonceCTRRecoverKeystream(nil, nil); err == nil {
+		t.Error("no error for no ciphertexts")
+	}
+}
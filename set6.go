@@ -0,0 +1,513 @@
+package cryptopals
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// RSAPublicKey represents an RSA public key.
+type RSAPublicKey struct {
+	N, E *big.Int
+}
+
+// RSAPrivateKey represents an RSA private key.
+type RSAPrivateKey struct {
+	RSAPublicKey
+	D, P, Q *big.Int
+
+	// Precomputed values for CRT decryption.
+	dp, dq, qinv *big.Int
+}
+
+// NewRSAPrivateKey returns a new RSA private key whose modulus
+// is exactly bits long. The public exponent is 65537.
+func NewRSAPrivateKey(bits int) (*RSAPrivateKey, error) {
+	if bits < 16 {
+		return nil, fmt.Errorf("invalid key size %d", bits)
+	}
+
+	e := big.NewInt(65537)
+	for {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if p.Cmp(q) == 0 || n.BitLen() != bits {
+			continue
+		}
+
+		key, err := newRSAPrivateKey(p, q, e)
+		if err != nil { // e isn't invertible, so try again.
+			continue
+		}
+		return key, nil
+	}
+}
+
+// newRSAPrivateKey returns the RSA private key with primes p and q
+// and public exponent e. It fails if e isn't invertible.
+func newRSAPrivateKey(p, q, e *big.Int) (*RSAPrivateKey, error) {
+	var (
+		pm1 = new(big.Int).Sub(p, big1)
+		qm1 = new(big.Int).Sub(q, big1)
+		phi = new(big.Int).Mul(pm1, qm1)
+	)
+
+	d := new(big.Int).ModInverse(e, phi)
+	if d == nil {
+		return nil, fmt.Errorf("e not invertible")
+	}
+
+	return &RSAPrivateKey{
+		RSAPublicKey: RSAPublicKey{N: new(big.Int).Mul(p, q), E: new(big.Int).Set(e)},
+		D:            d,
+		P:            new(big.Int).Set(p),
+		Q:            new(big.Int).Set(q),
+		dp:           new(big.Int).Mod(d, pm1),
+		dq:           new(big.Int).Mod(d, qm1),
+		qinv:         new(big.Int).ModInverse(q, p),
+	}, nil
+}
+
+// Public returns the public half of k.
+func (k *RSAPrivateKey) Public() *RSAPublicKey {
+	return &k.RSAPublicKey
+}
+
+// Encrypt encrypts a plaintext with textbook RSA.
+func (k *RSAPublicKey) Encrypt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, k.E, k.N)
+}
+
+// Decrypt decrypts a ciphertext with textbook RSA.
+func (k *RSAPrivateKey) Decrypt(c *big.Int) *big.Int {
+	// Garner's algorithm, since the attacks make a lot of queries.
+	var (
+		m1 = new(big.Int).Exp(c, k.dp, k.P)
+		m2 = new(big.Int).Exp(c, k.dq, k.Q)
+	)
+	m1.Sub(m1, m2).Mul(m1, k.qinv).Mod(m1, k.P)
+	return m1.Mul(m1, k.Q).Add(m1, m2)
+}
+
+// sha256DigestInfo is the DER prefix of a SHA-256 DigestInfo.
+var sha256DigestInfo = []byte{
+	0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+	0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20,
+}
+
+// pkcs1v15SignPad returns the PKCS#1 v1.5 signature block of k bytes
+// for the SHA-256 hash of msg.
+func pkcs1v15SignPad(msg []byte, k int) (*big.Int, error) {
+	t := len(sha256DigestInfo) + sha256.Size
+	if k < t+11 {
+		return nil, fmt.Errorf("key too short")
+	}
+
+	res := make([]byte, k)
+	res[1] = 1
+	for i := 2; i < k-t-1; i++ {
+		res[i] = 0xff
+	}
+	sum := sha256.Sum256(msg)
+	copy(res[k-t:], sha256DigestInfo)
+	copy(res[k-sha256.Size:], sum[:])
+	return new(big.Int).SetBytes(res), nil
+}
+
+// Sign returns the PKCS#1 v1.5 signature of the SHA-256 hash of msg.
+func (k *RSAPrivateKey) Sign(msg []byte) (*big.Int, error) {
+	m, err := pkcs1v15SignPad(msg, (k.N.BitLen()+7)/8)
+	if err != nil {
+		return nil, err
+	}
+	return k.Decrypt(m), nil
+}
+
+// Verify reports whether sig is a valid signature of msg.
+func (k *RSAPublicKey) Verify(msg []byte, sig *big.Int) bool {
+	if sig.Sign() < 0 || sig.Cmp(k.N) >= 0 {
+		return false
+	}
+	m, err := pkcs1v15SignPad(msg, (k.N.BitLen()+7)/8)
+	if err != nil {
+		return false
+	}
+	return k.Encrypt(sig).Cmp(m) == 0
+}
+
+// RSAParityOracle represents an RSA decryption oracle that only
+// reveals whether a plaintext is even.
+type RSAParityOracle struct {
+	key *RSAPrivateKey
+}
+
+// NewRSAParityOracle returns a new RSAParityOracle with a random
+// key of the given size.
+func NewRSAParityOracle(bits int) (*RSAParityOracle, error) {
+	key, err := NewRSAPrivateKey(bits)
+	if err != nil {
+		return nil, err
+	}
+	return &RSAParityOracle{key: key}, nil
+}
+
+// PublicKey returns the oracle's public key.
+func (o *RSAParityOracle) PublicKey() *RSAPublicKey {
+	return o.key.Public()
+}
+
+// IsEven returns true if ct decrypts to an even plaintext.
+func (o *RSAParityOracle) IsEven(ct *big.Int) bool {
+	return o.key.Decrypt(ct).Bit(0) == 0
+}
+
+// Even is IsEven for a big-endian ciphertext, so the oracle can be
+// used as a ParityOracle.
+func (o *RSAParityOracle) Even(ct []byte) (bool, error) {
+	return o.IsEven(new(big.Int).SetBytes(ct)), nil
+}
+
+// RSAParityRecoverPT recovers the plaintext of ct with a parity
+// oracle for big-endian ciphertexts under pub. Each step doubles the
+// plaintext and halves the interval [lo, hi) it could be in. If
+// progress isn't nil, it's called with the largest plaintext still
+// possible after every step. It stops early if ctx is cancelled.
+func RSAParityRecoverPT(ctx context.Context, pub *RSAPublicKey, oracle ParityOracle, ct *big.Int, progress func(upper *big.Int)) (*big.Int, error) {
+	var (
+		double = new(big.Int).Exp(big2, pub.E, pub.N) // Enc(2)
+		c      = new(big.Int).Set(ct)
+		lo     = new(big.Rat)
+		hi     = new(big.Rat).SetInt(pub.N)
+		mid    = new(big.Rat)
+		half   = big.NewRat(1, 2)
+	)
+
+	for i := 0; i < pub.N.BitLen(); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// If 2m doesn't wrap around N, it stays even, so m < N/2.
+		c.Mul(c, double).Mod(c, pub.N)
+		mid.Add(lo, hi).Mul(mid, half)
+		even, err := oracle.Even(c.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if even {
+			hi.Set(mid)
+		} else {
+			lo.Set(mid)
+		}
+
+		if progress != nil {
+			progress(new(big.Int).Sub(ratCeil(hi), big1))
+		}
+	}
+
+	// The interval is now narrower than 1, so only one integer is
+	// left in it.
+	return ratCeil(lo), nil
+}
+
+// ratFloor returns the floor of a rational.
+func ratFloor(r *big.Rat) *big.Int {
+	return new(big.Int).Div(r.Num(), r.Denom()) // Denom is positive.
+}
+
+// ratCeil returns the ceiling of a rational.
+func ratCeil(r *big.Rat) *big.Int {
+	n := new(big.Int).Neg(r.Num())
+	return n.Div(n, r.Denom()).Neg(n)
+}
+
+// PKCS1v15Pad pads a message for RSA encryption using PKCS#1 v1.5,
+// returning a block of k bytes.
+func PKCS1v15Pad(msg []byte, k int) ([]byte, error) {
+	if len(msg) > k-11 {
+		return nil, fmt.Errorf("message too long")
+	}
+
+	res := make([]byte, k)
+	res[1] = 2
+
+	ps := res[2 : k-len(msg)-1]
+	if _, err := rand.Read(ps); err != nil {
+		return nil, err
+	}
+	for i := range ps { // The padding string must be nonzero.
+		for ps[i] == 0 {
+			if _, err := rand.Read(ps[i : i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	copy(res[k-len(msg):], msg)
+	return res, nil
+}
+
+// PKCS1v15Unpad unpads a PKCS#1 v1.5 encryption block.
+func PKCS1v15Unpad(b []byte) ([]byte, error) {
+	if len(b) < 11 || b[0] != 0 || b[1] != 2 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	i := bytes.IndexByte(b[2:], 0)
+	if i < 8 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return b[2+i+1:], nil
+}
+
+// PKCS1v15Oracle represents an RSA decryption oracle that only
+// reveals whether a plaintext starts with 00 02.
+type PKCS1v15Oracle struct {
+	key *RSAPrivateKey
+}
+
+// NewPKCS1v15Oracle returns a new PKCS1v15Oracle with a random
+// key of the given size.
+func NewPKCS1v15Oracle(bits int) (*PKCS1v15Oracle, error) {
+	key, err := NewRSAPrivateKey(bits)
+	if err != nil {
+		return nil, err
+	}
+	return &PKCS1v15Oracle{key: key}, nil
+}
+
+// PublicKey returns the oracle's public key.
+func (o *PKCS1v15Oracle) PublicKey() *RSAPublicKey {
+	return o.key.Public()
+}
+
+// IsValid returns true if ct decrypts to a block starting
+// with 00 02.
+func (o *PKCS1v15Oracle) IsValid(ct *big.Int) bool {
+	k := (o.key.N.BitLen() + 7) / 8
+	pt := o.key.Decrypt(ct).FillBytes(make([]byte, k))
+	return pt[0] == 0 && pt[1] == 2
+}
+
+// Valid is IsValid for a big-endian ciphertext, so the oracle can be
+// used as a ValidityOracle.
+func (o *PKCS1v15Oracle) Valid(ct []byte) (bool, error) {
+	return o.IsValid(new(big.Int).SetBytes(ct)), nil
+}
+
+// interval represents the closed interval [a, b].
+type interval struct {
+	a, b *big.Int
+}
+
+// BleichenbacherRecoverPT recovers the padded plaintext of ct with
+// a PKCS#1 v1.5 padding oracle, which reports whether a big-endian
+// ciphertext under pub decrypts to a block starting with 00 02. It
+// uses Bleichenbacher's 1998 attack, and also returns the number of
+// oracle queries made.
+func BleichenbacherRecoverPT(pub *RSAPublicKey, oracle ValidityOracle, ct *big.Int) (*big.Int, int, error) {
+	var (
+		n       = pub.N
+		k       = (n.BitLen() + 7) / 8
+		queries int
+	)
+	if k < 11 {
+		return nil, 0, fmt.Errorf("modulus too small")
+	}
+
+	var (
+		b  = new(big.Int).Lsh(big1, uint(8*(k-2)))
+		b2 = new(big.Int).Mul(b, big2)
+		b3 = new(big.Int).Mul(b, big3)
+	)
+
+	// conforming checks whether c0 * s^e is PKCS conforming. If the
+	// oracle fails, it ends any search by saying yes, and the error
+	// is returned once the search is over.
+	var failed error
+	conforming := func(c0, s *big.Int) bool {
+		if failed != nil {
+			return true
+		}
+		c := pub.Encrypt(s)
+		c.Mul(c, c0).Mod(c, n)
+		ok, err := oracle.Valid(c.Bytes())
+		if err != nil {
+			failed = err
+			return true
+		}
+		queries++
+		return ok
+	}
+
+	// Step 1: blinding. Real PKCS#1 v1.5 ciphertexts skip this.
+	var (
+		s0 = big.NewInt(1)
+		c0 = new(big.Int).Set(ct)
+	)
+	if !conforming(c0, s0) {
+		for {
+			var err error
+			s0, err = rand.Int(rand.Reader, n)
+			if err != nil {
+				return nil, queries, err
+			}
+			if s0.Sign() != 0 && conforming(ct, s0) {
+				break
+			}
+		}
+		c0.Exp(s0, pub.E, n).Mul(c0, ct).Mod(c0, n)
+	}
+	if failed != nil {
+		return nil, queries, failed
+	}
+
+	var (
+		m = []interval{{a: new(big.Int).Set(b2), b: new(big.Int).Sub(b3, big1)}}
+		s *big.Int
+	)
+
+	for i := 1; ; i++ {
+		switch {
+		case i == 1: // Step 2a: search from n/3B.
+			s = ceilDiv(n, b3)
+			for !conforming(c0, s) {
+				s.Add(s, big1)
+			}
+		case len(m) > 1: // Step 2b: search with more than one interval left.
+			s = new(big.Int).Add(s, big1)
+			for !conforming(c0, s) {
+				s.Add(s, big1)
+			}
+		default: // Step 2c: search with one interval left.
+			s = bleichenbacherStep2c(m[0], s, n, b2, b3, func(s *big.Int) bool {
+				return conforming(c0, s)
+			})
+		}
+		if failed != nil {
+			return nil, queries, failed
+		}
+
+		// Step 3: narrow the set of solutions.
+		m = bleichenbacherStep3(m, s, n, b2, b3)
+		if len(m) == 0 {
+			return nil, queries, fmt.Errorf("no intervals left")
+		}
+
+		// Step 4: compute the solution.
+		if len(m) == 1 && m[0].a.Cmp(m[0].b) == 0 {
+			res := new(big.Int).ModInverse(s0, n)
+			res.Mul(res, m[0].a).Mod(res, n)
+			return res, queries, nil
+		}
+	}
+}
+
+// bleichenbacherStep2c searches for the next s when only one
+// interval is left.
+func bleichenbacherStep2c(in interval, prev, n, b2, b3 *big.Int, conforming func(*big.Int) bool) *big.Int {
+	// r >= 2(b*prev - 2B)/n
+	r := new(big.Int).Mul(in.b, prev)
+	r.Sub(r, b2).Mul(r, big2)
+	r = ceilDiv(r, n)
+
+	var (
+		lo = new(big.Int)
+		hi = new(big.Int)
+		rn = new(big.Int)
+	)
+
+	for ; ; r.Add(r, big1) {
+		// (2B + rn)/b <= s < (3B + rn)/a
+		rn.Mul(r, n)
+		lo = ceilDiv(lo.Add(b2, rn), in.b)
+		hi = ceilDiv(hi.Add(b3, rn), in.a)
+
+		for s := lo; s.Cmp(hi) < 0; s.Add(s, big1) {
+			if conforming(s) {
+				return s
+			}
+		}
+	}
+}
+
+// bleichenbacherStep3 narrows the intervals in m given a
+// conforming s.
+func bleichenbacherStep3(m []interval, s, n, b2, b3 *big.Int) []interval {
+	var (
+		res   []interval
+		b3m1  = new(big.Int).Sub(b3, big1)
+		rn    = new(big.Int)
+		tmp   = new(big.Int)
+		rLo   = new(big.Int)
+		rHi   = new(big.Int)
+		bound = new(big.Int)
+	)
+
+	for _, in := range m {
+		// (as - 3B + 1)/n <= r <= (bs - 2B)/n
+		rLo.Mul(in.a, s).Sub(rLo, b3m1)
+		rLo = ceilDiv(rLo, n)
+		rHi.Mul(in.b, s).Sub(rHi, b2)
+		rHi.Div(rHi, n)
+
+		for r := rLo; r.Cmp(rHi) <= 0; r.Add(r, big1) {
+			rn.Mul(r, n)
+
+			a := ceilDiv(tmp.Add(b2, rn), s)
+			if a.Cmp(in.a) < 0 {
+				a.Set(in.a)
+			}
+
+			b := bound.Add(b3m1, rn)
+			b = new(big.Int).Div(b, s)
+			if b.Cmp(in.b) > 0 {
+				b.Set(in.b)
+			}
+
+			if a.Cmp(b) <= 0 {
+				res = mergeInterval(res, interval{a: a, b: b})
+			}
+		}
+	}
+
+	return res
+}
+
+// mergeInterval adds in to a set of disjoint intervals, merging
+// any that overlap.
+func mergeInterval(m []interval, in interval) []interval {
+	res := make([]interval, 0, len(m)+1)
+	for _, v := range m {
+		if v.b.Cmp(in.a) < 0 || in.b.Cmp(v.a) < 0 { // Disjoint.
+			res = append(res, v)
+			continue
+		}
+		if v.a.Cmp(in.a) < 0 {
+			in.a = v.a
+		}
+		if v.b.Cmp(in.b) > 0 {
+			in.b = v.b
+		}
+	}
+	return append(res, in)
+}
+
+// ceilDiv returns ceil(x/y) for y > 0.
+func ceilDiv(x, y *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(x, y, new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, big1)
+	}
+	return q
+}
@@ -0,0 +1,224 @@
+package cryptopals
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestRSAPrivateKey(t *testing.T) {
+	t.Parallel()
+	m := big.NewInt(42)
+
+	key, err := NewRSAPrivateKey(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.N.BitLen() != 512 {
+		t.Errorf("got %d-bit modulus, want 512", key.N.BitLen())
+	}
+
+	got := key.Decrypt(key.Encrypt(m))
+	if got.Cmp(m) != 0 {
+		t.Errorf("got %v, want %v", got, m)
+	}
+}
+
+func TestRSAPrivateKey_Sign(t *testing.T) {
+	t.Parallel()
+	msg := []byte("hi mom")
+
+	key, err := NewRSAPrivateKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Verify(msg, sig) {
+		t.Error("signature didn't verify")
+	}
+	if key.Verify([]byte("hi dad"), sig) {
+		t.Error("signature verified for the wrong message")
+	}
+
+	// It's standard PKCS#1 v1.5, so the standard library agrees.
+	var (
+		pub    = &rsa.PublicKey{N: key.N, E: int(key.E.Int64())}
+		hashed = sha256.Sum256(msg)
+	)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig.FillBytes(make([]byte, 128))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChallenge46(t *testing.T) {
+	t.Parallel()
+	want := new(big.Int).SetBytes(HelperDecodeBase64(t, "VGhhdCdzIHdoeSBJIGZvdW5kIHlvdSBkb24ndCBwbGF5IGFyb3VuZCB3aXRoIHRoZSBGdW5reSBDb2xkIE1lZGluYQ"))
+
+	oracle, err := NewRSAParityOracle(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := oracle.PublicKey().Encrypt(want)
+
+	var (
+		steps int
+		last  *big.Int
+	)
+	progress := func(upper *big.Int) {
+		steps++
+		last = upper
+		if steps%128 == 0 { // Hollywood style, but less spammy.
+			t.Logf("step %d: %q", steps, upper.Bytes())
+		}
+	}
+
+	got, err := RSAParityRecoverPT(context.Background(), oracle.PublicKey(), oracle, ct, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(want) != 0 {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	if steps != 1024 || last.Cmp(got) != 0 {
+		t.Errorf("got %d steps ending at %x, want 1024 ending at %x", steps, last, got)
+	}
+
+	t.Logf("solve: %s", got.Bytes())
+}
+
+func TestRSAParityRecoverPT_Edges(t *testing.T) {
+	t.Parallel()
+	oracle, err := NewRSAParityOracle(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := oracle.PublicKey()
+
+	// N-1 doubles to an odd number at every step but the last, so
+	// the upper bound only moves once, right at the end.
+	for _, want := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(pub.N, big.NewInt(2)),
+		new(big.Int).Sub(pub.N, big.NewInt(1)),
+	} {
+		got, err := RSAParityRecoverPT(context.Background(), pub, oracle, pub.Encrypt(want), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("got %x, want %x", got, want)
+		}
+	}
+}
+
+func TestRSAParityRecoverPT_Cancel(t *testing.T) {
+	t.Parallel()
+	oracle, err := NewRSAParityOracle(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := oracle.PublicKey().Encrypt(big.NewInt(1337))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var steps int
+	progress := func(*big.Int) {
+		steps++
+		if steps == 10 {
+			cancel()
+		}
+	}
+
+	// One query per step, through a meter to count them.
+	m := &OracleMeter{}
+	_, err = RSAParityRecoverPT(ctx, oracle.PublicKey(), m.Parity(oracle), ct, progress)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if steps != 10 || m.Queries() != 10 {
+		t.Errorf("got %d steps and %d queries, want 10 each", steps, m.Queries())
+	}
+}
+
+func TestPKCS1v15Pad(t *testing.T) {
+	t.Parallel()
+	var (
+		msg = []byte("kick it, CC")
+		k   = 32
+	)
+
+	padded, err := PKCS1v15Pad(msg, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(padded) != k || padded[0] != 0 || padded[1] != 2 {
+		t.Errorf("invalid padding: %x", padded)
+	}
+
+	got, err := PKCS1v15Unpad(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("got %q, want %q", got, msg)
+	}
+
+	if _, err := PKCS1v15Pad(msg, 21); err == nil {
+		t.Errorf("no error for oversized message")
+	}
+}
+
+func TestChallenge47(t *testing.T) {
+	t.Parallel()
+	testBleichenbacher(t, 256, []byte("kick it, CC"))
+}
+
+func TestChallenge48(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping 768-bit attack in short mode")
+	}
+	testBleichenbacher(t, 768, []byte("kick it, CC"))
+}
+
+func testBleichenbacher(t *testing.T, bits int, want []byte) {
+	t.Helper()
+
+	oracle, err := NewPKCS1v15Oracle(bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := oracle.PublicKey()
+
+	padded, err := PKCS1v15Pad(want, (bits+7)/8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := pub.Encrypt(new(big.Int).SetBytes(padded))
+
+	meter := new(OracleMeter)
+	m, queries, err := BleichenbacherRecoverPT(pub, meter.Validity(oracle), ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != meter.Queries() {
+		t.Errorf("got %d queries, meter counted %d", queries, meter.Queries())
+	}
+	got, err := PKCS1v15Unpad(m.FillBytes(make([]byte, len(padded))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	t.Logf("solve after %d queries: %s", queries, got)
+}
@@ -0,0 +1,1266 @@
+package cryptopals
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CBCMAC represents a CBC-MAC on AES.
+type CBCMAC struct {
+	cbc *CBCCipher
+}
+
+// NewCBCMAC returns a new CBCMAC.
+func NewCBCMAC(key []byte) (*CBCMAC, error) {
+	cbc, err := NewCBCCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &CBCMAC{cbc: cbc}, nil
+}
+
+// Sum returns the MAC of a message using an IV. The message is
+// padded with PKCS#7 first. If iv is nil, a zero IV is used.
+func (m *CBCMAC) Sum(msg, iv []byte) ([]byte, error) {
+	if iv == nil {
+		iv = make([]byte, 16)
+	}
+	ct, err := m.cbc.Encrypt(PKCS7Pad(msg, 16), iv)
+	if err != nil {
+		return nil, err
+	}
+	return ct[len(ct)-16:], nil
+}
+
+// Verify returns true if tag is the MAC of a message using an IV.
+// If iv is nil, a zero IV is used.
+func (m *CBCMAC) Verify(msg, iv, tag []byte) bool {
+	want, err := m.Sum(msg, iv)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(want, tag) == 1
+}
+
+// CBCMACBank represents a bank server that accepts transfer
+// requests signed with CBC-MAC.
+type CBCMACBank struct {
+	mac      *CBCMAC
+	balances map[int]int
+}
+
+// NewCBCMACBank returns a new CBCMACBank with the given starting
+// balances.
+func NewCBCMACBank(balances map[int]int) (*CBCMACBank, error) {
+	key := make([]byte, 16)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := NewCBCMAC(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &CBCMACBank{mac: mac, balances: make(map[int]int)}
+	for id, amount := range balances {
+		b.balances[id] = amount
+	}
+	return b, nil
+}
+
+// Balance returns the balance of an account.
+func (b *CBCMACBank) Balance(id int) int {
+	return b.balances[id]
+}
+
+// transfer moves money between accounts, if there's enough.
+func (b *CBCMACBank) transfer(from, to, amount int) error {
+	if amount <= 0 || b.balances[from] < amount {
+		return fmt.Errorf("invalid transfer of %d from %d", amount, from)
+	}
+	b.balances[from] -= amount
+	b.balances[to] += amount
+	return nil
+}
+
+// Process verifies and executes a transfer request of the form
+// message || IV || MAC, where the message looks like
+// "from=#{from_id}&to=#{to_id}&amount=#{amount}".
+func (b *CBCMACBank) Process(req []byte) error {
+	if len(req) < 32 {
+		return fmt.Errorf("invalid request")
+	}
+	var (
+		msg = req[:len(req)-32]
+		iv  = req[len(req)-32 : len(req)-16]
+		tag = req[len(req)-16:]
+	)
+	if !b.mac.Verify(msg, iv, tag) {
+		return fmt.Errorf("invalid MAC")
+	}
+
+	v, err := url.ParseQuery(string(msg))
+	if err != nil {
+		return err
+	}
+	var ids [3]int
+	for i, k := range []string{"from", "to", "amount"} {
+		ids[i], err = strconv.Atoi(v.Get(k))
+		if err != nil {
+			return err
+		}
+	}
+	return b.transfer(ids[0], ids[1], ids[2])
+}
+
+// ProcessList verifies and executes a transfer request of the form
+// message || MAC, where the message looks like
+// "from=#{from_id}&tx_list=#{transactions}" and transactions looks
+// like "to:amount(;to:amount)*". The IV is fixed at zero.
+// Malformed transactions are skipped.
+func (b *CBCMACBank) ProcessList(req []byte) error {
+	if len(req) < 16 {
+		return fmt.Errorf("invalid request")
+	}
+	var (
+		msg = req[:len(req)-16]
+		tag = req[len(req)-16:]
+	)
+	if !b.mac.Verify(msg, nil, tag) {
+		return fmt.Errorf("invalid MAC")
+	}
+
+	if !bytes.HasPrefix(msg, []byte("from=")) {
+		return fmt.Errorf("invalid message")
+	}
+	i := bytes.Index(msg, []byte("&tx_list="))
+	if i < 0 {
+		return fmt.Errorf("invalid message")
+	}
+	from, err := strconv.Atoi(string(msg[len("from="):i]))
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range bytes.Split(msg[i+len("&tx_list="):], []byte(";")) {
+		parts := bytes.Split(tx, []byte(":"))
+		if len(parts) != 2 {
+			continue
+		}
+		to, err := strconv.Atoi(string(parts[0]))
+		if err != nil {
+			continue
+		}
+		amount, err := strconv.Atoi(string(parts[1]))
+		if err != nil {
+			continue
+		}
+		_ = b.transfer(from, to, amount) // Skip failed transfers too.
+	}
+	return nil
+}
+
+// Client returns a client that signs requests for an account.
+// Like the web client in the challenge, it holds the bank's key,
+// but only signs requests from its own account.
+func (b *CBCMACBank) Client(id int) *CBCMACClient {
+	return &CBCMACClient{id: id, mac: b.mac}
+}
+
+// CBCMACClient represents a client for a CBCMACBank.
+type CBCMACClient struct {
+	id  int
+	mac *CBCMAC
+}
+
+// ID returns the client's account ID.
+func (c *CBCMACClient) ID() int {
+	return c.id
+}
+
+// Transfer returns a signed request to transfer money from the
+// client's account, for use with CBCMACBank.Process. A random
+// IV is used.
+func (c *CBCMACClient) Transfer(to, amount int) ([]byte, error) {
+	msg := []byte(fmt.Sprintf("from=%d&to=%d&amount=%d", c.id, to, amount))
+
+	iv := make([]byte, 16)
+	_, err := rand.Read(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := c.mac.Sum(msg, iv)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(msg, iv...), tag...), nil
+}
+
+// CBCMACTx represents a transaction in a transfer list.
+type CBCMACTx struct {
+	To, Amount int
+}
+
+// TransferList returns a signed request to make several transfers
+// from the client's account, for use with CBCMACBank.ProcessList.
+func (c *CBCMACClient) TransferList(txs []CBCMACTx) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "from=%d&tx_list=", c.id)
+	for i, tx := range txs {
+		if i > 0 {
+			buf.WriteByte(';')
+		}
+		fmt.Fprintf(&buf, "%d:%d", tx.To, tx.Amount)
+	}
+	msg := buf.Bytes()
+
+	tag, err := c.mac.Sum(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(msg, tag...), nil
+}
+
+// CBCMACForgeTransfer forges a request that transfers money from
+// a victim to the attacker, given the attacker's client. Since the
+// IV is attacker-controlled, it can absorb changes to the first
+// block. The account IDs must have the same number of digits.
+func CBCMACForgeTransfer(attacker TransferOracle, victim, amount int) ([]byte, error) {
+	var (
+		from = []byte(fmt.Sprintf("from=%d&", attacker.ID()))
+		to   = []byte(fmt.Sprintf("from=%d&", victim))
+	)
+	if len(from) != len(to) || len(from) > 16 {
+		return nil, fmt.Errorf("incompatible account IDs")
+	}
+
+	req, err := attacker.Transfer(attacker.ID(), amount)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		msg = req[:len(req)-32]
+		iv  = req[len(req)-32 : len(req)-16]
+	)
+	for i := range from {
+		iv[i] ^= from[i] ^ to[i]
+	}
+	copy(msg, to)
+
+	return req, nil
+}
+
+// CBCMACExtendTransfers extends a captured transfer list request
+// with a transfer of amount to the attacker. The forged request
+// is the captured message and its padding, followed by the
+// attacker's own signed message with its first block XORed against
+// the captured MAC. The first block turns into garbage, but the
+// MAC of the whole thing is the attacker's MAC.
+func CBCMACExtendTransfers(captured []byte, attacker TransferOracle, amount int) ([]byte, error) {
+	if len(captured) < 16 {
+		return nil, fmt.Errorf("invalid request")
+	}
+	var (
+		msg = captured[:len(captured)-16]
+		tag = captured[len(captured)-16:]
+	)
+
+	// The first transaction starts in the garbage block, and the
+	// separator after it doesn't.
+	req, err := attacker.TransferList([]CBCMACTx{
+		{To: attacker.ID(), Amount: 0},
+		{To: attacker.ID(), Amount: amount},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := PKCS7Pad(msg, 16)
+	first, err := XORBytes(req[:16], tag)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, first...)
+	return append(res, req[16:]...), nil
+}
+
+// CBCMACCollide returns a message that starts with prefix and has
+// the same MAC as target, using a zero IV. The glue block after the
+// prefix never contains a line break, so a prefix ending in a
+// JavaScript comment hides everything after it.
+func CBCMACCollide(m *CBCMAC, target, prefix []byte) ([]byte, error) {
+	if len(target) < 16 {
+		return nil, fmt.Errorf("target too short")
+	}
+
+	// Fill the prefix out to a block boundary.
+	p := append([]byte{}, prefix...)
+	for len(p)%16 != 0 {
+		p = append(p, ' ')
+	}
+	iv := make([]byte, 16)
+
+	for {
+		ct, err := m.cbc.Encrypt(p, iv)
+		if err != nil {
+			return nil, err
+		}
+
+		// Cancel out the state after the prefix, so the rest of
+		// the chain matches the target's.
+		glue, err := XORBytes(ct[len(ct)-16:], target[:16])
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.ContainsAny(glue, "\r\n") {
+			res := append(p, glue...)
+			return append(res, target[16:]...), nil
+		}
+
+		// Try again with a different state.
+		p = append(p, bytes.Repeat([]byte{' '}, 16)...)
+	}
+}
+
+// CompressionOracle represents an oracle that compresses and
+// encrypts HTTP requests containing a secret session cookie, and
+// only reveals the length of the result.
+type CompressionOracle struct {
+	sessionID string
+	cbc       bool
+}
+
+// NewCTRCompressionOracle returns a new CompressionOracle that
+// encrypts with AES-CTR.
+func NewCTRCompressionOracle(sessionID string) *CompressionOracle {
+	return &CompressionOracle{sessionID: sessionID}
+}
+
+// NewCBCCompressionOracle returns a new CompressionOracle that
+// encrypts with AES-CBC and PKCS#7 padding.
+func NewCBCCompressionOracle(sessionID string) *CompressionOracle {
+	return &CompressionOracle{sessionID: sessionID, cbc: true}
+}
+
+// FormatRequest returns an HTTP request with a body and the
+// oracle's session cookie.
+func (o *CompressionOracle) FormatRequest(body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "POST / HTTP/1.1\nHost: hapless.com\nCookie: sessionid=%s\nContent-Length: %d\n", o.sessionID, len(body))
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// zlibWriters caches zlib writers, which are expensive to allocate.
+var zlibWriters = sync.Pool{
+	New: func() interface{} {
+		return zlib.NewWriter(nil)
+	},
+}
+
+// Len formats, compresses and encrypts a request with a body under
+// a random key, then returns the length of the ciphertext.
+func (o *CompressionOracle) Len(body []byte) (int, error) {
+	var buf bytes.Buffer
+	w := zlibWriters.Get().(*zlib.Writer)
+	defer zlibWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(o.FormatRequest(body)); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+
+	key := make([]byte, 16)
+	iv := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return 0, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return 0, err
+	}
+
+	if o.cbc {
+		c, err := NewCBCCipher(key)
+		if err != nil {
+			return 0, err
+		}
+		ct, err := c.Encrypt(PKCS7Pad(buf.Bytes(), 16), iv)
+		if err != nil {
+			return 0, err
+		}
+		return len(ct), nil
+	}
+
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	ct := make([]byte, buf.Len())
+	cipher.NewCTR(b, iv).XORKeyStream(ct, buf.Bytes())
+	return len(ct), nil
+}
+
+// CompressionRecoverSessionID recovers the oracle's Base64 session
+// ID one character at a time. A correct guess repeats more of the
+// cookie, so it compresses better, but the difference is often
+// less than a byte. To see it anyway, each guess is measured behind
+// several lengths of junk that doesn't compress. In CBC mode, this
+// slides the compressed request across a block boundary, and the
+// better guesses spill over into a new block later than the rest.
+// Ties are broken by guessing more characters at once.
+func CompressionRecoverSessionID(oracle LenOracle) (string, error) {
+	const (
+		alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=\n"
+		maxLen   = 256
+		maxDepth = 3
+	)
+
+	known := []byte("sessionid=")
+	for len(known) < maxLen {
+		// Each guess is a string of one or more characters.
+		guesses := make([][]byte, len(alphabet))
+		for i := range guesses {
+			guesses[i] = []byte{alphabet[i]}
+		}
+
+		for depth := 1; ; depth++ {
+			best, err := compressionBestGuesses(oracle, known, guesses)
+			if err != nil {
+				return "", err
+			}
+			if sameFirstByte(best) {
+				guesses = best
+				break
+			}
+			if depth == maxDepth {
+				return "", fmt.Errorf("ambiguous guesses after %q", known)
+			}
+
+			// Look one character further ahead.
+			guesses = guesses[:0]
+			for _, g := range best {
+				for i := 0; i < len(alphabet); i++ {
+					guesses = append(guesses, append(append([]byte{}, g...), alphabet[i]))
+				}
+			}
+		}
+
+		c := guesses[0][0]
+		if c == '\n' {
+			return string(known[len("sessionid="):]), nil
+		}
+		known = append(known, c)
+	}
+
+	return "", fmt.Errorf("session ID too long")
+}
+
+// compressionBestGuesses returns the guesses that compress best
+// after known. To cancel out how cheap each character is on its
+// own, every guess is also measured with junk between it and
+// known, and only the difference counts. The differences are
+// summed over every junk prefix length up to a block.
+func compressionBestGuesses(oracle LenOracle, known []byte, guesses [][]byte) ([][]byte, error) {
+	const sep = "~#"
+
+	var (
+		best  [][]byte
+		bestN = 0
+	)
+
+	for i, g := range guesses {
+		var n int
+		for pad := 0; pad < 16; pad++ {
+			prefix := append(compressionJunk(pad), known...)
+
+			// known || guess || sep
+			body := append(append(append([]byte{}, prefix...), g...), sep...)
+			l, err := oracle.Len(body)
+			if err != nil {
+				return nil, err
+			}
+			n += l
+
+			// known || sep || guess
+			body = append(append(append([]byte{}, prefix...), sep...), g...)
+			l, err = oracle.Len(body)
+			if err != nil {
+				return nil, err
+			}
+			n -= l
+		}
+
+		switch {
+		case i == 0 || n < bestN:
+			bestN = n
+			best = [][]byte{g}
+		case n == bestN:
+			best = append(best, g)
+		}
+	}
+
+	return best, nil
+}
+
+// sameFirstByte returns true if every slice in bs starts with
+// the same byte.
+func sameFirstByte(bs [][]byte) bool {
+	for _, b := range bs {
+		if b[0] != bs[0][0] {
+			return false
+		}
+	}
+	return true
+}
+
+// compressionJunk returns n bytes that compress poorly and
+// aren't in the Base64 alphabet.
+func compressionJunk(n int) []byte {
+	const junk = "!@#$%^&*()-_[]{}<>~|;,.?`'\""
+
+	res := make([]byte, n)
+	for i := range res {
+		// Walk the junk with a stride that avoids short repeats.
+		res[i] = junk[(i*i+3*i)%len(junk)]
+	}
+	return res
+}
+
+// MDHash represents a cheap toy Merkle-Damgård hash. Its compression
+// function encrypts a message block with ECBCipher, keyed by the
+// state padded with zeros, and truncates the result to the state size.
+// It counts how many times the compression function is called.
+type MDHash struct {
+	bits  int
+	size  int // state size in bytes, rounded up
+	iv    []byte
+	calls int64
+}
+
+// MDBlockSize is the block size of MDHash in bytes.
+const MDBlockSize = 16
+
+// NewMDHash returns a new MDHash with a state of bits bits, from 16
+// to 32. If bits isn't a multiple of 8, the state is rounded up to
+// whole bytes, and the unused low bits of the last byte are always
+// zero.
+func NewMDHash(bits int) (*MDHash, error) {
+	if bits < 16 || bits > 32 {
+		return nil, fmt.Errorf("invalid state size %d", bits)
+	}
+	h := &MDHash{bits: bits, size: (bits + 7) / 8}
+	h.iv = make([]byte, h.size)
+	for i := range h.iv { // Anything's fine, as long as it's fixed.
+		h.iv[i] = byte(0x5c + i)
+	}
+	h.truncate(h.iv)
+	return h, nil
+}
+
+// Size returns the state size in bytes, rounded up.
+func (h *MDHash) Size() int {
+	return h.size
+}
+
+// Bits returns the state size in bits.
+func (h *MDHash) Bits() int {
+	return h.bits
+}
+
+// truncate clears the unused low bits of the last byte of state.
+func (h *MDHash) truncate(state []byte) {
+	state[h.size-1] &= 0xff << uint(8*h.size-h.bits)
+}
+
+// IV returns the initial state.
+func (h *MDHash) IV() []byte {
+	return append([]byte{}, h.iv...)
+}
+
+// Calls returns the number of compression function calls so far.
+func (h *MDHash) Calls() int64 {
+	return atomic.LoadInt64(&h.calls)
+}
+
+// Compress returns the state after processing one block.
+func (h *MDHash) Compress(state, block []byte) []byte {
+	atomic.AddInt64(&h.calls, 1)
+
+	key := make([]byte, 16)
+	copy(key, state)
+	ecb, err := NewECBCipher(key)
+	if err != nil {
+		panic(err) // The key is always 16 bytes.
+	}
+	next := ecb.Encrypt(block[:MDBlockSize])[:h.size]
+	h.truncate(next)
+	return next
+}
+
+// Iterate returns the state after processing whole blocks of msg,
+// without any padding. It panics if msg isn't block-aligned.
+func (h *MDHash) Iterate(state, msg []byte) []byte {
+	if len(msg)%MDBlockSize != 0 {
+		panic("invalid MDHash message")
+	}
+	for i := 0; i < len(msg); i += MDBlockSize {
+		state = h.Compress(state, msg[i:i+MDBlockSize])
+	}
+	return state
+}
+
+// Sum returns the hash of msg. The message is padded with a one
+// bit, zeros, and its length in bits.
+func (h *MDHash) Sum(msg []byte) []byte {
+	return h.Iterate(h.iv, append(append([]byte{}, msg...), MDPadding(len(msg))...))
+}
+
+// MDPadding returns the padding MDHash appends to a message
+// of n bytes.
+func MDPadding(n int) []byte {
+	pad := []byte{0x80}
+	for (n+len(pad))%MDBlockSize != MDBlockSize-8 {
+		pad = append(pad, 0)
+	}
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(n)*8)
+	return append(pad, l[:]...)
+}
+
+// mdCollision finds two different blocks that collide from state.
+// It returns both blocks and the resulting state.
+func mdCollision(h *MDHash, state []byte) (a, b, next []byte, err error) {
+	block := make([]byte, MDBlockSize)
+	if _, err := rand.Read(block[:8]); err != nil {
+		return nil, nil, nil, err
+	}
+
+	seen := make(map[string][]byte)
+	for i := uint64(0); ; i++ {
+		binary.BigEndian.PutUint64(block[8:], i)
+		s := h.Compress(state, block)
+
+		if prev, ok := seen[string(s)]; ok {
+			return prev, append([]byte{}, block...), s, nil
+		}
+		seen[string(s)] = append([]byte{}, block...)
+	}
+}
+
+// Multicollision represents 2^n messages with the same MDHash
+// state, built from n pairs of colliding blocks.
+type Multicollision struct {
+	// Pairs holds a pair of colliding blocks for each step.
+	Pairs [][2][]byte
+	// State is the state after any of the messages.
+	State []byte
+}
+
+// JouxMulticollision finds n successive block collisions from state,
+// giving 2^n colliding messages for the price of n birthday attacks.
+func JouxMulticollision(h *MDHash, state []byte, n int) (*Multicollision, error) {
+	mc := &Multicollision{State: state}
+	for i := 0; i < n; i++ {
+		if err := mc.Extend(h); err != nil {
+			return nil, err
+		}
+	}
+	return mc, nil
+}
+
+// Extend adds one more block collision, doubling the number
+// of messages.
+func (mc *Multicollision) Extend(h *MDHash) error {
+	a, b, next, err := mdCollision(h, mc.State)
+	if err != nil {
+		return err
+	}
+	mc.Pairs = append(mc.Pairs, [2][]byte{a, b})
+	mc.State = next
+	return nil
+}
+
+// Len returns the number of messages.
+func (mc *Multicollision) Len() uint64 {
+	return 1 << uint(len(mc.Pairs))
+}
+
+// Message returns the ith message. Bit j of i chooses a block
+// from the jth pair.
+func (mc *Multicollision) Message(i uint64) []byte {
+	res := make([]byte, 0, len(mc.Pairs)*MDBlockSize)
+	for j, p := range mc.Pairs {
+		res = append(res, p[i>>uint(j)&1]...)
+	}
+	return res
+}
+
+// CascadeCollision finds two messages that collide under the cascade
+// f(x) || g(x), where f is the cheaper hash. It builds a Joux
+// multicollision in f with enough messages to expect a birthday
+// collision in g among them, and adds more f collisions until
+// there is one.
+func CascadeCollision(f, g *MDHash) (m1, m2 []byte, err error) {
+	mc, err := JouxMulticollision(f, f.IV(), g.Bits()/2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		seen := make(map[string]uint64, mc.Len())
+		i, j, ok := cascadeSearch(g, mc, g.IV(), 0, 0, seen)
+		if ok {
+			return mc.Message(i), mc.Message(j), nil
+		}
+
+		if err := mc.Extend(f); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// cascadeSearch walks every message in mc depth-first, so that
+// messages with a common prefix share g compression calls. It
+// returns the indices of two messages with the same g state.
+func cascadeSearch(g *MDHash, mc *Multicollision, state []byte, depth int, i uint64, seen map[string]uint64) (uint64, uint64, bool) {
+	if depth == len(mc.Pairs) {
+		if j, ok := seen[string(state)]; ok {
+			return j, i, true
+		}
+		seen[string(state)] = i
+		return 0, 0, false
+	}
+
+	for k, block := range mc.Pairs[depth] {
+		next := g.Compress(state, block)
+		if a, b, ok := cascadeSearch(g, mc, next, depth+1, i|uint64(k)<<uint(depth), seen); ok {
+			return a, b, true
+		}
+	}
+	return 0, 0, false
+}
+
+// ExpandableMessage represents a set of messages whose lengths
+// cover every number of blocks in [k, k+2^k-1], all ending in the
+// same MDHash state.
+type ExpandableMessage struct {
+	// Pairs holds, for each step i from 0 to k-1, a single block and a
+	// message of 2^(k-1-i)+1 blocks that collide.
+	Pairs [][2][]byte
+	// State is the state after any of the messages.
+	State []byte
+}
+
+// NewExpandableMessage builds an expandable message from state,
+// with k steps.
+func NewExpandableMessage(h *MDHash, state []byte, k int) (*ExpandableMessage, error) {
+	em := &ExpandableMessage{State: state}
+	for i := 0; i < k; i++ {
+		short, long, next, err := mdLengthCollision(h, em.State, 1<<uint(k-1-i))
+		if err != nil {
+			return nil, err
+		}
+		em.Pairs = append(em.Pairs, [2][]byte{short, long})
+		em.State = next
+	}
+	return em, nil
+}
+
+// K returns the number of steps.
+func (em *ExpandableMessage) K() int {
+	return len(em.Pairs)
+}
+
+// Message returns the message that's n blocks long. It fails if n
+// isn't in [k, k+2^k-1].
+func (em *ExpandableMessage) Message(n int) ([]byte, error) {
+	k := em.K()
+	if n < k || n > k+1<<uint(k)-1 {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+
+	// Each long message adds 2^(k-1-i) blocks over the short one,
+	// so the extra blocks are just n-k written in binary.
+	var (
+		extra = n - k
+		res   []byte
+	)
+	for i, p := range em.Pairs {
+		if extra>>uint(k-1-i)&1 == 1 {
+			res = append(res, p[1]...)
+		} else {
+			res = append(res, p[0]...)
+		}
+	}
+	return res, nil
+}
+
+// mdLengthCollision finds a single block and a message of n+1 blocks
+// that collide from state. The long message is n dummy blocks
+// followed by a final block.
+func mdLengthCollision(h *MDHash, state []byte, n int) (short, long, next []byte, err error) {
+	dummy := make([]byte, n*MDBlockSize)
+	longState := h.Iterate(state, dummy)
+
+	// Collect final states for the short side, then search with the
+	// long side until one matches.
+	shorts := make(map[string][]byte)
+	block := make([]byte, MDBlockSize)
+	if _, err := rand.Read(block[:8]); err != nil {
+		return nil, nil, nil, err
+	}
+	limit := uint64(1) << uint(h.Bits()/2+1)
+	for i := uint64(0); i < limit; i++ {
+		binary.BigEndian.PutUint64(block[8:], i)
+		shorts[string(h.Compress(state, block))] = append([]byte{}, block...)
+	}
+
+	for i := uint64(0); ; i++ {
+		binary.BigEndian.PutUint64(block[8:], i)
+		s := h.Compress(longState, block)
+		if sb, ok := shorts[string(s)]; ok {
+			return sb, append(dummy, block...), s, nil
+		}
+	}
+}
+
+// SecondPreimage finds a different message with the same MDHash
+// as target, using the Kelsey-Schneier expandable message attack.
+// The target should be at least k+2^k blocks long. It returns the
+// forged message and the index of the target block where the
+// forgery links in: the forgery and the target share every block
+// after that one.
+func SecondPreimage(h *MDHash, target []byte, k int) ([]byte, int, error) {
+	blocks := len(target) / MDBlockSize
+	if blocks < k+1<<uint(k) {
+		return nil, 0, fmt.Errorf("target too short")
+	}
+
+	// Map each intermediate state to the index of the block that
+	// produced it. Only states the expandable message can reach
+	// are useful.
+	var (
+		states = make(map[string]int)
+		state  = h.IV()
+	)
+	for i := 0; i < blocks; i++ {
+		state = h.Compress(state, target[i*MDBlockSize:(i+1)*MDBlockSize])
+		if i >= k && i <= k+1<<uint(k)-1 {
+			states[string(state)] = i
+		}
+	}
+
+	em, err := NewExpandableMessage(h, h.IV(), k)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Find a bridge block from the expandable message into the
+	// target's chain. If it lands after block i, the prefix must be
+	// i blocks long, so the bridge replaces block i.
+	block := make([]byte, MDBlockSize)
+	if _, err := rand.Read(block[:8]); err != nil {
+		return nil, 0, err
+	}
+	for j := uint64(0); ; j++ {
+		binary.BigEndian.PutUint64(block[8:], j)
+		i, ok := states[string(h.Compress(em.State, block))]
+		if !ok {
+			continue
+		}
+
+		prefix, err := em.Message(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		res := append(prefix, block...)
+		return append(res, target[(i+1)*MDBlockSize:]...), i, nil
+	}
+}
+
+// Diamond represents a diamond structure: a binary tree of block
+// collisions that funnels 2^k starting states into one final state.
+type Diamond struct {
+	// Bits is the state size in bits of the hash the tree was
+	// built for.
+	Bits int
+	// States holds the states at each level of the tree, starting
+	// with the 2^k leaves and ending with the root.
+	States [][][]byte
+	// Blocks holds a block for each state below the root. Compressing
+	// States[i][j] with Blocks[i][j] gives States[i+1][j/2].
+	Blocks [][][]byte
+}
+
+// BuildDiamond builds a diamond structure with 2^k leaves. The
+// collisions on each level are found in parallel by the given
+// number of workers. The leaves are distinct states, so k must be
+// less than the hash's state size in bits.
+func BuildDiamond(h *MDHash, k, workers int) (*Diamond, error) {
+	if k < 1 || k > 24 || k >= h.Bits() {
+		return nil, fmt.Errorf("invalid diamond size %d", k)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Start from distinct random states.
+	var (
+		leaves = make([][]byte, 0, 1<<uint(k))
+		seen   = make(map[string]bool)
+	)
+	for len(leaves) < 1<<uint(k) {
+		s := make([]byte, h.Size())
+		if _, err := rand.Read(s); err != nil {
+			return nil, err
+		}
+		h.truncate(s)
+		if !seen[string(s)] {
+			seen[string(s)] = true
+			leaves = append(leaves, s)
+		}
+	}
+
+	d := &Diamond{Bits: h.Bits(), States: [][][]byte{leaves}}
+	for level := leaves; len(level) > 1; {
+		var (
+			next   = make([][]byte, len(level)/2)
+			blocks = make([][]byte, len(level))
+			errs   = make([]error, len(next))
+			pairs  = make(chan int)
+			wg     sync.WaitGroup
+		)
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range pairs {
+					blocks[2*j], blocks[2*j+1], next[j], errs[j] = mdPairCollision(h, level[2*j], level[2*j+1])
+				}
+			}()
+		}
+		for j := range next {
+			pairs <- j
+		}
+		close(pairs)
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		d.States = append(d.States, next)
+		d.Blocks = append(d.Blocks, blocks)
+		level = next
+	}
+
+	return d, nil
+}
+
+// mdPairCollision finds blocks x and y such that compressing a with
+// x and b with y gives the same state.
+func mdPairCollision(h *MDHash, a, b []byte) (x, y, next []byte, err error) {
+	block := make([]byte, MDBlockSize)
+	if _, err := rand.Read(block[:8]); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		seen  = make(map[string][]byte)
+		limit = uint64(1) << uint(h.Bits()/2)
+	)
+	for i := uint64(0); ; i++ {
+		binary.BigEndian.PutUint64(block[8:], i)
+
+		// Fill up on a's side first, then switch over to b's.
+		if i < limit {
+			seen[string(h.Compress(a, block))] = append([]byte{}, block...)
+			continue
+		}
+		s := h.Compress(b, block)
+		if x, ok := seen[string(s)]; ok {
+			return x, append([]byte{}, block...), s, nil
+		}
+	}
+}
+
+// LoadDiamond reads a diamond structure saved with Save.
+func LoadDiamond(r io.Reader) (*Diamond, error) {
+	var d Diamond
+	if err := gob.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	if len(d.States) == 0 || len(d.States) != len(d.Blocks)+1 {
+		return nil, fmt.Errorf("invalid diamond")
+	}
+	return &d, nil
+}
+
+// Save writes the diamond structure to w.
+func (d *Diamond) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(d)
+}
+
+// K returns the depth of the tree.
+func (d *Diamond) K() int {
+	return len(d.Blocks)
+}
+
+// Root returns the state at the root of the tree.
+func (d *Diamond) Root() []byte {
+	return d.States[len(d.States)-1][0]
+}
+
+// Commit returns the hash to publish ahead of time. It's the hash of
+// any message made of n blocks of prediction, a linking block, and
+// a path through the tree.
+func (d *Diamond) Commit(h *MDHash, n int) []byte {
+	length := (n + 1 + d.K()) * MDBlockSize
+	return h.Iterate(d.Root(), MDPadding(length))
+}
+
+// Herd returns a message that starts with prefix and hashes to the
+// value from Commit. The prefix must be as many whole blocks as
+// were committed to.
+func (d *Diamond) Herd(h *MDHash, prefix []byte) ([]byte, error) {
+	if h.Bits() != d.Bits {
+		return nil, fmt.Errorf("wrong hash size %d", h.Bits())
+	}
+	if len(prefix)%MDBlockSize != 0 {
+		return nil, fmt.Errorf("prefix isn't block-aligned")
+	}
+
+	leaves := make(map[string]int, len(d.States[0]))
+	for i, s := range d.States[0] {
+		leaves[string(s)] = i
+	}
+
+	var (
+		state = h.Iterate(h.IV(), prefix)
+		block = make([]byte, MDBlockSize)
+	)
+	if _, err := rand.Read(block[:8]); err != nil {
+		return nil, err
+	}
+	for i := uint64(0); ; i++ {
+		binary.BigEndian.PutUint64(block[8:], i)
+		j, ok := leaves[string(h.Compress(state, block))]
+		if !ok {
+			continue
+		}
+
+		res := append(append([]byte{}, prefix...), block...)
+		for _, blocks := range d.Blocks {
+			res = append(res, blocks[j]...)
+			j /= 2
+		}
+		return res, nil
+	}
+}
+
+// RC4 represents an RC4 stream cipher. It implements cipher.Stream.
+type RC4 struct {
+	s    [256]byte
+	i, j uint8
+}
+
+// NewRC4 returns a new RC4 cipher. The key must be between 1 and
+// 256 bytes long.
+func NewRC4(key []byte) (*RC4, error) {
+	if len(key) < 1 || len(key) > 256 {
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+
+	var c RC4
+	for i := range c.s {
+		c.s[i] = byte(i)
+	}
+	var j uint8
+	for i, k := 0, 0; i < len(c.s); i, k = i+1, k+1 {
+		if k == len(key) {
+			k = 0
+		}
+		j += c.s[i] + key[k]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+	}
+	return &c, nil
+}
+
+// XORKeyStream XORs each byte in src with a byte from the
+// keystream, and writes the result to dst.
+func (c *RC4) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	i, j := c.i, c.j
+	for k, v := range src {
+		i++
+		j += c.s[i]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+		dst[k] = v ^ c.s[c.s[i]+c.s[j]]
+	}
+	c.i, c.j = i, j
+}
+
+// RC4Oracle represents an encryption oracle that appends a secret
+// cookie to requests and encrypts them with RC4 under a fresh
+// random key every time.
+type RC4Oracle struct {
+	cookie []byte
+}
+
+// NewRC4Oracle returns a new RC4Oracle.
+func NewRC4Oracle(cookie []byte) *RC4Oracle {
+	return &RC4Oracle{cookie: cookie}
+}
+
+// Encrypt encrypts request || cookie under a random 128-bit key.
+// It's safe for concurrent use.
+func (o *RC4Oracle) Encrypt(request []byte) ([]byte, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	c, err := NewRC4(key)
+	if err != nil {
+		return nil, err
+	}
+	pt := append(append([]byte{}, request...), o.cookie...)
+	c.XORKeyStream(pt, pt)
+	return pt, nil
+}
+
+// RC4RecoverCookie recovers the oracle's cookie from the single-byte
+// biases in the RC4 keystream: the 16th byte leans towards 240,
+// and the 32nd towards 224. A request prefix shifts each cookie byte
+// under one of those positions, and the most common ciphertext byte
+// there gives it away. Each prefix length is sampled the given
+// number of times, split across workers. Fewer samples finish sooner,
+// but are less likely to recover every byte correctly. The cookie
+// can be at most 32 bytes long.
+func RC4RecoverCookie(oracle EncryptOracle, samples, workers int) ([]byte, error) {
+	ct, err := oracle.Encrypt(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(ct) > 32 {
+		return nil, fmt.Errorf("cookie too long")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	cookie := make([]byte, len(ct))
+	for pad := 0; pad < 16; pad++ {
+		// The prefix puts cookie bytes 15-pad and 31-pad under
+		// keystream bytes 16 and 32.
+		var (
+			i16 = 15 - pad
+			i32 = 31 - pad
+		)
+		if i16 >= len(cookie) && i32 >= len(cookie) {
+			continue
+		}
+
+		counts, err := rc4Count(oracle, make([]byte, pad), samples, workers)
+		if err != nil {
+			return nil, err
+		}
+
+		if i16 < len(cookie) {
+			cookie[i16] = mostCommon(&counts[0]) ^ 240
+		}
+		if i32 < len(cookie) {
+			cookie[i32] = mostCommon(&counts[1]) ^ 224
+		}
+	}
+
+	return cookie, nil
+}
+
+// rc4Count encrypts request with the oracle the given number of
+// times, and counts the ciphertext bytes at positions 16 and 32.
+func rc4Count(oracle EncryptOracle, request []byte, samples, workers int) ([2][256]int, error) {
+	var (
+		res  [2][256]int
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, workers)
+	)
+
+	for w := 0; w < workers; w++ {
+		n := samples / workers
+		if w < samples%workers {
+			n++
+		}
+
+		wg.Add(1)
+		go func(w, n int) {
+			defer wg.Done()
+
+			var counts [2][256]int
+			for i := 0; i < n; i++ {
+				ct, err := oracle.Encrypt(request)
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				if len(ct) > 15 {
+					counts[0][ct[15]]++
+				}
+				if len(ct) > 31 {
+					counts[1][ct[31]]++
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i := range counts {
+				for b, c := range counts[i] {
+					res[i][b] += c
+				}
+			}
+		}(w, n)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// mostCommon returns the byte with the highest count.
+func mostCommon(counts *[256]int) byte {
+	var best int
+	for b, c := range counts {
+		if c > counts[best] {
+			best = b
+		}
+	}
+	return byte(best)
+}
@@ -0,0 +1,561 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCBCMAC(t *testing.T) {
+	t.Parallel()
+	var (
+		key = []byte("YELLOW SUBMARINE")
+		msg = []byte("alert('MZA who was that?');\n")
+		iv  = bytes.Repeat([]byte{1}, 16)
+	)
+
+	m, err := NewCBCMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := m.Sum(msg, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Verify(msg, iv, tag) {
+		t.Errorf("tag %x didn't verify", tag)
+	}
+	if m.Verify(msg, nil, tag) {
+		t.Errorf("tag %x verified with the wrong IV", tag)
+	}
+}
+
+func TestChallenge49_Transfer(t *testing.T) {
+	t.Parallel()
+	var (
+		victim   = 1
+		attacker = 2
+		amount   = 1000000
+	)
+
+	bank, err := NewCBCMACBank(map[int]int{victim: amount})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The attacker only needs one request signed.
+	m := &OracleMeter{Budget: 1}
+	req, err := CBCMACForgeTransfer(m.Transfer(bank.Client(attacker)), victim, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.Process(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bank.Balance(attacker); got != amount {
+		t.Errorf("got balance %d, want %d", got, amount)
+	}
+	t.Logf("solve: %q", req[:len(req)-32])
+}
+
+func TestChallenge49_TransferList(t *testing.T) {
+	t.Parallel()
+	var (
+		victim   = 1
+		friend   = 3
+		attacker = 2
+		amount   = 1000000
+	)
+
+	bank, err := NewCBCMACBank(map[int]int{victim: amount + 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The victim sends money to a friend, and the attacker
+	// captures the request.
+	captured, err := bank.Client(victim).TransferList([]CBCMACTx{
+		{To: friend, Amount: 20},
+		{To: friend, Amount: 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &OracleMeter{Budget: 1}
+	req, err := CBCMACExtendTransfers(captured, m.Transfer(bank.Client(attacker)), amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.ProcessList(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := bank.Balance(attacker); got != amount {
+		t.Errorf("got balance %d, want %d", got, amount)
+	}
+	t.Logf("solve: %q", req[:len(req)-16])
+}
+
+func TestChallenge50(t *testing.T) {
+	t.Parallel()
+	var (
+		key    = []byte("YELLOW SUBMARINE")
+		target = []byte("alert('MZA who was that?');\n")
+		prefix = []byte("alert('Ayo, the Wu is back!');//")
+		want   = HelperDecodeHex(t, "296b8d7cb78a243dda4d0a61d33bbdd1")
+	)
+
+	m, err := NewCBCMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := m.Sum(target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tag, want) {
+		t.Fatalf("got target MAC %x, want %x", tag, want)
+	}
+
+	forged, err := CBCMACCollide(m, target, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(forged, prefix) || bytes.ContainsAny(forged[:len(forged)-1], "\r\n") {
+		t.Errorf("bad forgery: %q", forged)
+	}
+
+	got, err := m.Sum(forged, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+
+	t.Logf("solve: %q", forged)
+}
+
+func TestCompressionOracle(t *testing.T) {
+	t.Parallel()
+	var (
+		oracle = NewCTRCompressionOracle("TmV2ZXIgcmV2ZWFsIHRoZSBXdS1UYW5nIFNlY3JldCE=")
+		want   = "POST / HTTP/1.1\nHost: hapless.com\nCookie: sessionid=TmV2ZXIgcmV2ZWFsIHRoZSBXdS1UYW5nIFNlY3JldCE=\nContent-Length: 5\nhello"
+	)
+
+	got := oracle.FormatRequest([]byte("hello"))
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestChallenge51(t *testing.T) {
+	t.Parallel()
+	cases := map[string]func(string) *CompressionOracle{
+		"CTR": NewCTRCompressionOracle,
+		"CBC": NewCBCCompressionOracle,
+	}
+
+	for name, newOracle := range cases {
+		newOracle := newOracle
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				t.Fatal(err)
+			}
+			want := base64.StdEncoding.EncodeToString(b)
+
+			m := &OracleMeter{}
+			got, err := CompressionRecoverSessionID(m.Len(newOracle(want)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+			t.Logf("%d queries", m.Queries())
+		})
+	}
+}
+
+func TestMDHash(t *testing.T) {
+	t.Parallel()
+	h, err := NewMDHash(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 40; n++ {
+		if got := (n + len(MDPadding(n))) % MDBlockSize; got != 0 {
+			t.Errorf("%d: padded length off by %d", n, got)
+		}
+	}
+
+	var (
+		a = h.Sum([]byte("YELLOW SUBMARINE"))
+		b = h.Sum([]byte("YELLOW SUBMARINE"))
+		c = h.Sum([]byte("YELLOW SUBMARINF"))
+	)
+	if len(a) != 2 || !bytes.Equal(a, b) {
+		t.Errorf("got %x and %x for the same message", a, b)
+	}
+	if bytes.Equal(a, c) {
+		t.Errorf("got %x for different messages", a)
+	}
+	if got := h.Calls(); got != 6 {
+		t.Errorf("got %d calls, want 6", got)
+	}
+
+	// States that aren't whole bytes are rounded up, with the
+	// spare bits cleared.
+	h, err = NewMDHash(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range [][]byte{h.IV(), h.Sum([]byte("YELLOW SUBMARINE"))} {
+		if len(s) != 3 || s[2]&0x0f != 0 {
+			t.Errorf("got %x for a 20-bit state", s)
+		}
+	}
+
+	for _, bits := range []int{15, 33} {
+		if _, err := NewMDHash(bits); err == nil {
+			t.Errorf("no error for a %d-bit state", bits)
+		}
+	}
+}
+
+func TestJouxMulticollision(t *testing.T) {
+	t.Parallel()
+	n := 4
+
+	// A state that isn't whole bytes exercises the rounding.
+	h, err := NewMDHash(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := JouxMulticollision(h, h.IV(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mc.Len() != 1<<n {
+		t.Fatalf("got %d messages, want %d", mc.Len(), 1<<n)
+	}
+	seen := make(map[string]bool)
+	for i := uint64(0); i < mc.Len(); i++ {
+		m := mc.Message(i)
+		seen[string(m)] = true
+		if got := h.Iterate(h.IV(), m); !bytes.Equal(got, mc.State) {
+			t.Errorf("message %d: got state %x, want %x", i, got, mc.State)
+		}
+	}
+	if len(seen) != 1<<n {
+		t.Errorf("got %d distinct messages, want %d", len(seen), 1<<n)
+	}
+}
+
+func TestChallenge52(t *testing.T) {
+	t.Parallel()
+	f, err := NewMDHash(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewMDHash(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m1, m2, err := CascadeCollision(f, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(m1, m2) {
+		t.Fatalf("got identical messages %x", m1)
+	}
+	if !bytes.Equal(f.Sum(m1), f.Sum(m2)) || !bytes.Equal(g.Sum(m1), g.Sum(m2)) {
+		t.Errorf("no collision: %x, %x", m1, m2)
+	}
+
+	// A direct birthday attack on the cascade takes about 2^24 calls.
+	// This should take about 16 * 2^8 calls to f and 2^17 calls to g,
+	// and a few times that if the first multicollision isn't enough.
+	t.Logf("calls: f %d, g %d", f.Calls(), g.Calls())
+	if f.Calls() > 1<<16 || g.Calls() > 1<<21 {
+		t.Errorf("too many calls: f %d, g %d", f.Calls(), g.Calls())
+	}
+}
+
+func TestExpandableMessage(t *testing.T) {
+	t.Parallel()
+	k := 4
+
+	h, err := NewMDHash(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em, err := NewExpandableMessage(h, h.IV(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := k; n <= k+1<<k-1; n++ {
+		m, err := em.Message(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m) != n*MDBlockSize {
+			t.Errorf("got %d blocks, want %d", len(m)/MDBlockSize, n)
+		}
+		if got := h.Iterate(h.IV(), m); !bytes.Equal(got, em.State) {
+			t.Errorf("%d blocks: got state %x, want %x", n, got, em.State)
+		}
+	}
+
+	if _, err := em.Message(k - 1); err == nil {
+		t.Errorf("no error for a short message")
+	}
+	if _, err := em.Message(k + 1<<k); err == nil {
+		t.Errorf("no error for a long message")
+	}
+}
+
+func TestChallenge53(t *testing.T) {
+	t.Parallel()
+	k := 10
+
+	h, err := NewMDHash(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := make([]byte, (1<<k+k)*MDBlockSize+5)
+	if _, err := rand.Read(target); err != nil {
+		t.Fatal(err)
+	}
+
+	got, i, err := SecondPreimage(h, target, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(target) {
+		t.Errorf("got length %d, want %d", len(got), len(target))
+	}
+	if bytes.Equal(got, target) {
+		t.Errorf("got the target back")
+	}
+	if !bytes.Equal(got[(i+1)*MDBlockSize:], target[(i+1)*MDBlockSize:]) {
+		t.Errorf("forgery doesn't match the target after block %d", i)
+	}
+	if a, b := h.Sum(got), h.Sum(target); !bytes.Equal(a, b) {
+		t.Errorf("got hash %x, want %x", a, b)
+	}
+
+	t.Logf("linked in at block %d after %d calls", i, h.Calls())
+}
+
+func TestDiamond_SaveLoad(t *testing.T) {
+	t.Parallel()
+	h, err := NewMDHash(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := BuildDiamond(h, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadDiamond(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.K() != 3 || !bytes.Equal(got.Root(), d.Root()) {
+		t.Errorf("got depth %d and root %x, want 3 and %x", got.K(), got.Root(), d.Root())
+	}
+}
+
+func TestBuildDiamond_TooBig(t *testing.T) {
+	t.Parallel()
+	h, err := NewMDHash(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// There aren't enough states for 2^16 distinct leaves.
+	for _, k := range []int{0, 16, 17, 25} {
+		if _, err := BuildDiamond(h, k, 1); err == nil {
+			t.Errorf("no error for k = %d", k)
+		}
+	}
+}
+
+func TestChallenge54(t *testing.T) {
+	t.Parallel()
+	var (
+		k          = 8
+		prediction = []byte("Cardinals 4, Yankees 3. No trades.")
+	)
+
+	h, err := NewMDHash(24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := helperDiamond(t, h, k)
+
+	// Commit to a prediction that's three blocks long.
+	prefix := append(prediction, bytes.Repeat([]byte{' '}, 3*MDBlockSize-len(prediction))...)
+	want := d.Commit(h, 3)
+
+	got, err := d.Herd(h, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(got, prefix) {
+		t.Errorf("got %q, missing prediction", got)
+	}
+	if sum := h.Sum(got); !bytes.Equal(sum, want) {
+		t.Errorf("got hash %x, want %x", sum, want)
+	}
+
+	t.Logf("solve: %q", got)
+}
+
+// helperDiamond returns a diamond structure with 2^k leaves for h.
+// It's cached in the temporary directory across test runs, under a
+// name that covers the hash's size, IV and compression function.
+func helperDiamond(tb testing.TB, h *MDHash, k int) *Diamond {
+	tb.Helper()
+	var (
+		fp   = h.Compress(h.IV(), make([]byte, MDBlockSize))
+		name = filepath.Join(os.TempDir(), fmt.Sprintf("cryptopals-diamond-%d-%x-%x-%d.gob", h.Bits(), h.IV(), fp, k))
+	)
+
+	if f, err := os.Open(name); err == nil {
+		defer f.Close()
+		d, err := LoadDiamond(f)
+		switch {
+		case err != nil:
+			tb.Logf("ignoring cached diamond: %v", err)
+		case d.K() != k || d.Bits != h.Bits():
+			tb.Logf("ignoring cached diamond: got k = %d for %d bits, want k = %d for %d bits", d.K(), d.Bits, k, h.Bits())
+		case !bytes.Equal(h.Compress(d.States[0][0], d.Blocks[0][0]), d.States[1][0]):
+			tb.Logf("ignoring cached diamond: built with a different hash")
+		default:
+			return d
+		}
+	}
+
+	d, err := BuildDiamond(h, k, runtime.NumCPU())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// Write somewhere else first, so other runs never see half a file.
+	f, err := os.CreateTemp("", "cryptopals-diamond-*")
+	if err != nil {
+		tb.Logf("could not cache diamond: %v", err)
+		return d
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := d.Save(f); err != nil {
+		tb.Logf("could not cache diamond: %v", err)
+		return d
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		tb.Logf("could not cache diamond: %v", err)
+	}
+	return d
+}
+
+func TestRC4(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		key, pt string
+		ct      []byte
+	}{
+		{"Key", "Plaintext", HelperDecodeHex(t, "bbf316e8d940af0ad3")},
+		{"Wiki", "pedia", HelperDecodeHex(t, "1021bf0420")},
+		{"Secret", "Attack at dawn", HelperDecodeHex(t, "45a01f645fc35b383552544b9bf5")},
+	}
+
+	for _, tc := range cases {
+		c, err := NewRC4([]byte(tc.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Encrypt in two pieces to check the state carries over.
+		got := make([]byte, len(tc.pt))
+		c.XORKeyStream(got[:3], []byte(tc.pt[:3]))
+		c.XORKeyStream(got[3:], []byte(tc.pt[3:]))
+		if !bytes.Equal(got, tc.ct) {
+			t.Errorf("%s: got %x, want %x", tc.key, got, tc.ct)
+		}
+	}
+}
+
+func TestChallenge56(t *testing.T) {
+	t.Parallel()
+	cookie := HelperDecodeBase64(t, "QkUgU1VSRSBUTyBEUklOSyBZT1VSIE9WQUxUSU5F")
+
+	// A stand-in for RC4 with much stronger biases at positions 16
+	// and 32 checks that every cookie byte is read from the right
+	// position with the right bias, in a fraction of the samples.
+	biased := EncryptOracleFunc(func(request []byte) ([]byte, error) {
+		pt := append(append([]byte{}, request...), cookie...)
+		// Reach past position 32, and let the last byte pick
+		// whether this sample is biased.
+		ks := make([]byte, len(pt)+32)
+		if _, err := rand.Read(ks); err != nil {
+			return nil, err
+		}
+		if ks[len(ks)-1] < 64 {
+			ks[15], ks[31] = 240, 224
+		}
+		for i := range pt {
+			pt[i] ^= ks[i]
+		}
+		return pt, nil
+	})
+	got, err := RC4RecoverCookie(biased, 1<<12, runtime.NumCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, cookie) {
+		t.Errorf("biased: got %q, want %q", got, cookie)
+	}
+
+	if testing.Short() {
+		t.Skip("skipping RC4 bias attack in short mode")
+	}
+
+	// Against real RC4, the position 32 bias is too weak to recover
+	// reliably in a test, and every byte takes 2^24 samples or more.
+	// One byte under position 16 at 2^23 samples is very unlikely to
+	// come out wrong.
+	want := cookie[:1]
+	got, err = RC4RecoverCookie(NewRC4Oracle(want), 1<<23, runtime.NumCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,1490 @@
+package cryptopals
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// DHGroup represents a Diffie-Hellman group: a prime P, and a
+// generator G of a subgroup of prime order Q.
+type DHGroup struct {
+	P, G, Q *big.Int
+}
+
+// DHGroup57 returns the group from Challenge 57.
+func DHGroup57() *DHGroup {
+	return &DHGroup{
+		P: mustBigInt("7199773997391911030609999317773941274322764333428698921736339643928346453700085358802973900485592910475480089726140708102474957429903531369589969318716771"),
+		G: mustBigInt("4565356397095740655436854503483826832136106141639563487732438195343690437606117828318042418238184896212352329118608100083187535033402010599512641674644143"),
+		Q: mustBigInt("236234353446506858198510045061214171961"),
+	}
+}
+
+// DHGroup58 returns the group from Challenge 58.
+func DHGroup58() *DHGroup {
+	return &DHGroup{
+		P: mustBigInt("11470374874925275658116663507232161402086650258453896274534991676898999262641581519101074740642369848233294239851519212341844337347119899874391456329785623"),
+		G: mustBigInt("622952335333961296978159266084741085889881358738459939978290179936063635566740258555167783009058567397963466103140082647486611657350811560630587013183357"),
+		Q: mustBigInt("335062023296420808191071248367701059461"),
+	}
+}
+
+// mustBigInt parses a decimal integer. It panics on failure.
+func mustBigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(fmt.Sprintf("invalid integer %s", s))
+	}
+	return n
+}
+
+// DHMACMessage is the message a DHMACVictim authenticates.
+const DHMACMessage = "crazy flamboyant for the rap enjoyment"
+
+// DHMACVictim represents a party that completes Diffie-Hellman
+// with anyone's public key, and responds with a MAC of a fixed
+// message under the shared key. It never checks the public key.
+type DHMACVictim struct {
+	group *DHGroup
+	x     *big.Int
+}
+
+// NewDHMACVictim returns a new DHMACVictim with a random private key.
+func NewDHMACVictim(group *DHGroup) (*DHMACVictim, error) {
+	x, err := rand.Int(rand.Reader, group.Q) // [0, q)
+	if err != nil {
+		return nil, err
+	}
+	return &DHMACVictim{group: group, x: x}, nil
+}
+
+// PublicKey returns the victim's public key.
+func (v *DHMACVictim) PublicKey() *big.Int {
+	return new(big.Int).Exp(v.group.G, v.x, v.group.P)
+}
+
+// Respond completes Diffie-Hellman with the public key h, and
+// returns DHMACMessage and its MAC.
+func (v *DHMACVictim) Respond(h *big.Int) ([]byte, []byte) {
+	k := new(big.Int).Exp(h, v.x, v.group.P)
+	msg := []byte(DHMACMessage)
+	return msg, dhMAC(k, msg)
+}
+
+// Exchange is Respond for a big-endian public key, so the victim can
+// be used as a KeyExchangeOracle.
+func (v *DHMACVictim) Exchange(pub []byte) ([]byte, []byte, error) {
+	msg, mac := v.Respond(new(big.Int).SetBytes(pub))
+	return msg, mac, nil
+}
+
+// dhMAC returns the HMAC-SHA256 of msg under a shared key.
+func dhMAC(k *big.Int, msg []byte) []byte {
+	return sharedSecretMAC(k.Bytes(), msg)
+}
+
+// sharedSecretMAC returns the HMAC-SHA256 of msg under the bytes of
+// a shared secret.
+func sharedSecretMAC(secret, msg []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// bruteForceMAC finds the least k in [0, n) such that secret(k) is
+// the shared secret that produced mac. It's the discrete log of the
+// shared secret by brute force, so it only suits small n. Since k
+// goes up by one each time, secret can step from the last multiple
+// instead of starting over.
+func bruteForceMAC(n *big.Int, msg, mac []byte, secret func(k *big.Int) []byte) (*big.Int, error) {
+	for k := new(big.Int); k.Cmp(n) < 0; k.Add(k, big1) {
+		if hmac.Equal(sharedSecretMAC(secret(k), msg), mac) {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("no key found below %v", n)
+}
+
+// DHSubgroupRecoverKey recovers the victim's private key with a
+// subgroup-confinement attack, given a key exchange oracle for
+// big-endian public keys. The group's P-1 must have enough small
+// factors besides Q.
+func DHSubgroupRecoverKey(group *DHGroup, victim KeyExchangeOracle) (*big.Int, error) {
+	x, r, err := dhSubgroupResidues(group, victim, 1<<16, group.Q)
+	if err != nil {
+		return nil, err
+	}
+	if r.Cmp(group.Q) < 0 {
+		return nil, fmt.Errorf("not enough small factors")
+	}
+	return x, nil
+}
+
+// dhSubgroupResidues learns the victim's private key modulo small
+// primes r dividing (P-1)/Q. For each one, it sends an element h
+// of order r, so the shared key is one of only r values, and tries
+// them all against the MAC. It stops once the product of the primes
+// reaches limit, or when it runs out of primes up to bound. It
+// returns the key modulo that product, and the product itself.
+func dhSubgroupResidues(group *DHGroup, victim KeyExchangeOracle, bound uint64, limit *big.Int) (*big.Int, *big.Int, error) {
+	var (
+		pm1 = new(big.Int).Sub(group.P, big1)
+		j   = new(big.Int).Div(pm1, group.Q)
+	)
+	factors, _ := TrialDivision(j, bound)
+
+	var (
+		residues []*big.Int
+		moduli   []*big.Int
+		prod     = big.NewInt(1)
+	)
+	for _, f := range factors {
+		if prod.Cmp(limit) >= 0 {
+			break
+		}
+		if new(big.Int).Mod(group.Q, f.P).Sign() == 0 {
+			continue
+		}
+
+		h, err := elementOfOrder(group.P, f.P)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		msg, mac, err := victim.Exchange(h.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+		key := big.NewInt(1)
+		k, err := bruteForceMAC(f.P, msg, mac, func(k *big.Int) []byte {
+			if k.Sign() > 0 {
+				key.Mul(key, h).Mod(key, group.P)
+			}
+			return key.Bytes()
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		residues = append(residues, k)
+		moduli = append(moduli, f.P)
+		prod.Mul(prod, f.P)
+	}
+
+	return CRT(residues, moduli)
+}
+
+// elementOfOrder returns a random element of prime order r in the
+// multiplicative group mod p. The prime r must divide p-1.
+func elementOfOrder(p, r *big.Int) (*big.Int, error) {
+	e := new(big.Int).Sub(p, big1)
+	e.Div(e, r)
+
+	for {
+		h, err := rand.Int(rand.Reader, p)
+		if err != nil {
+			return nil, err
+		}
+		h.Exp(h, e, p)
+		if h.Cmp(big1) > 0 {
+			return h, nil
+		}
+	}
+}
+
+// DHKangarooRecoverKey recovers the victim's private key when P-1
+// doesn't have enough small factors for DHSubgroupRecoverKey alone.
+// Subgroup confinement gives the key x mod r for some r, so
+// x = n + m*r, and then y * g^-n = (g^r)^m with m in [0, (Q-1)/r]
+// is small enough for the kangaroo algorithm. The victim's public
+// key is y.
+func DHKangarooRecoverKey(ctx context.Context, group *DHGroup, y *big.Int, victim KeyExchangeOracle) (*big.Int, error) {
+	n, r, err := dhSubgroupResidues(group, victim, 1<<16, group.Q)
+	if err != nil {
+		return nil, err
+	}
+	if r.Cmp(group.Q) >= 0 {
+		return n, nil
+	}
+
+	var (
+		gr = new(big.Int).Exp(group.G, r, group.P)
+		yr = new(big.Int).Exp(group.G, n, group.P)
+		b  = new(big.Int).Sub(group.Q, big1)
+	)
+	yr.ModInverse(yr, group.P).Mul(yr, y).Mod(yr, group.P)
+	b.Div(b, r)
+
+	m, err := Kangaroo(ctx, gr, yr, group.P, new(big.Int), b, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m.Mul(m, r).Add(m, n), nil
+}
+
+// Challenge59InvalidCurves returns curves that share P and A with
+// Curve59, but not B, so their orders have different small factors.
+func Challenge59InvalidCurves() []*Curve {
+	c := Curve59()
+	return []*Curve{
+		{P: c.P, A: c.A, B: big.NewInt(210), Order: mustBigInt("233970423115425145550826547352470124412")},
+		{P: c.P, A: c.A, B: big.NewInt(504), Order: mustBigInt("233970423115425145544350131142039591210")},
+		{P: c.P, A: c.A, B: big.NewInt(727), Order: mustBigInt("233970423115425145545378039958152057148")},
+	}
+}
+
+// ECDHMACVictim is the elliptic curve version of DHMACVictim.
+type ECDHMACVictim struct {
+	curve    *Curve
+	d        *big.Int
+	validate bool
+}
+
+// NewECDHMACVictim returns a new ECDHMACVictim with a random private
+// key. If validate is true, it rejects points that aren't on the curve.
+func NewECDHMACVictim(curve *Curve, validate bool) (*ECDHMACVictim, error) {
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(curve.N, big1))
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, big1) // [1, n)
+	return &ECDHMACVictim{curve: curve, d: d, validate: validate}, nil
+}
+
+// PublicKey returns the victim's public key.
+func (v *ECDHMACVictim) PublicKey() *Point {
+	return v.curve.ScalarBaseMult(v.d)
+}
+
+// Respond completes ECDH with the public key h, and returns
+// DHMACMessage and its MAC.
+func (v *ECDHMACVictim) Respond(h *Point) ([]byte, []byte, error) {
+	if v.validate && !v.curve.IsOnCurve(h) {
+		return nil, nil, fmt.Errorf("invalid public key %v", h)
+	}
+	k := v.curve.ScalarMult(h, v.d)
+	msg := []byte(DHMACMessage)
+	return msg, ecdhMAC(v.curve, k, msg), nil
+}
+
+// Exchange is Respond for a public key encoded with Curve.Bytes, so
+// the victim can be used as a KeyExchangeOracle.
+func (v *ECDHMACVictim) Exchange(pub []byte) ([]byte, []byte, error) {
+	h, err := v.curve.ParsePoint(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.Respond(h)
+}
+
+// ecdhMAC returns the HMAC-SHA256 of msg under a shared point.
+func ecdhMAC(c *Curve, k *Point, msg []byte) []byte {
+	return sharedSecretMAC(c.Bytes(k), msg)
+}
+
+// ECInvalidCurveRecoverKey recovers the victim's private key with an
+// invalid-curve attack. The victim never uses B, so it accepts points
+// of small order r from the invalid curves, and the shared point
+// gives away the key mod r. With enough of these, the CRT does the
+// rest.
+func ECInvalidCurveRecoverKey(curve *Curve, invalid []*Curve, victim KeyExchangeOracle) (*big.Int, error) {
+	var (
+		residues []*big.Int
+		moduli   []*big.Int
+		prod     = big.NewInt(1)
+		seen     = make(map[string]bool)
+	)
+
+	for _, c := range invalid {
+		factors, _ := TrialDivision(c.Order, 1<<16)
+		for _, f := range factors {
+			if prod.Cmp(curve.N) >= 0 {
+				break
+			}
+			if seen[f.P.String()] {
+				continue
+			}
+			seen[f.P.String()] = true
+
+			h, err := c.PointOfOrder(f.P)
+			if err != nil {
+				return nil, err
+			}
+			msg, mac, err := victim.Exchange(c.Bytes(h))
+			if err != nil {
+				return nil, err
+			}
+			key := Identity()
+			k, err := bruteForceMAC(f.P, msg, mac, func(k *big.Int) []byte {
+				if k.Sign() > 0 {
+					key = c.Add(key, h)
+				}
+				return c.Bytes(key)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			residues = append(residues, k)
+			moduli = append(moduli, f.P)
+			prod.Mul(prod, f.P)
+		}
+	}
+	if prod.Cmp(curve.N) < 0 {
+		return nil, fmt.Errorf("not enough small factors")
+	}
+
+	x, _, err := CRT(residues, moduli)
+	return x, err
+}
+
+// MontgomeryDHMACVictim is the x-only version of ECDHMACVictim. It
+// only ever looks at u coordinates, so it can't tell points on the
+// curve from points on the twist.
+type MontgomeryDHMACVictim struct {
+	curve *MontgomeryCurve
+	d     *big.Int
+}
+
+// NewMontgomeryDHMACVictim returns a new MontgomeryDHMACVictim with a
+// random private key.
+func NewMontgomeryDHMACVictim(curve *MontgomeryCurve) (*MontgomeryDHMACVictim, error) {
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(curve.N, big1))
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, big1) // [1, n)
+	return &MontgomeryDHMACVictim{curve: curve, d: d}, nil
+}
+
+// PublicKey returns the u coordinate of the victim's public key.
+func (v *MontgomeryDHMACVictim) PublicKey() *big.Int {
+	return v.curve.Ladder(v.curve.U, v.d)
+}
+
+// Respond completes ECDH with the public key u, and returns
+// DHMACMessage and its MAC.
+func (v *MontgomeryDHMACVictim) Respond(u *big.Int) ([]byte, []byte) {
+	k := v.curve.Ladder(u, v.d)
+	msg := []byte(DHMACMessage)
+	return msg, montgomeryMAC(v.curve, k, msg)
+}
+
+// Exchange is Respond for a big-endian u coordinate, so the victim
+// can be used as a KeyExchangeOracle.
+func (v *MontgomeryDHMACVictim) Exchange(pub []byte) ([]byte, []byte, error) {
+	msg, mac := v.Respond(new(big.Int).SetBytes(pub))
+	return msg, mac, nil
+}
+
+// montgomeryMAC returns the HMAC-SHA256 of msg under a shared u
+// coordinate.
+func montgomeryMAC(c *MontgomeryCurve, k *big.Int, msg []byte) []byte {
+	return sharedSecretMAC(c.Bytes(k), msg)
+}
+
+// ECTwistRecoverKey recovers a private key d with u(dG) equal to the
+// victim's public key, using points of small order on the twist.
+// It uses the prime factors of the twist order up to bound.
+//
+// Each point of prime order r dividing the twist order gives away
+// the key mod r, but only up to sign, since kQ and -kQ share a u
+// coordinate. Points of order r0*ri tell us whether the residues
+// mod r0 and ri have the same sign, so the CRT gives the key mod R
+// up to one global sign. The kangaroo algorithm on the Weierstrass
+// form finishes the job for both signs at once, and whichever
+// finishes first wins. Since u(dG) = u(-dG), the result may be
+// either d or N-d.
+//
+// The victim's public key is pub, and it takes public keys encoded
+// with MontgomeryCurve.Bytes.
+func ECTwistRecoverKey(ctx context.Context, curve *MontgomeryCurve, pub *big.Int, victim KeyExchangeOracle, bound uint64) (*big.Int, error) {
+	twist := curve.TwistOrder()
+	factors, _ := TrialDivision(twist, bound)
+
+	var (
+		residues []*big.Int
+		moduli   []*big.Int
+	)
+	for _, f := range factors {
+		if f.P.Cmp(big2) == 0 { // Order 2 points share u = 0 with the identity.
+			continue
+		}
+		u, err := montgomeryPointOfOrder(curve, twist, f.P)
+		if err != nil {
+			return nil, err
+		}
+		msg, mac, err := victim.Exchange(curve.Bytes(u))
+		if err != nil {
+			return nil, err
+		}
+		// kQ and -kQ share a u coordinate, so only k up to r/2 are
+		// tried, and the key is k or -k mod r.
+		half := new(big.Int).Rsh(f.P, 1)
+		k, err := bruteForceMAC(half.Add(half, big1), msg, mac, montgomeryMultiples(curve, u))
+		if err != nil {
+			return nil, err
+		}
+		residues = append(residues, k)
+		moduli = append(moduli, f.P)
+	}
+	if len(moduli) == 0 {
+		return nil, fmt.Errorf("no small factors")
+	}
+
+	// Make every residue's sign agree with the first nonzero one. A
+	// zero residue is its own negation, so it can't be compared
+	// against, and doesn't need fixing.
+	ref := -1
+	for i, k := range residues {
+		if k.Sign() != 0 {
+			ref = i
+			break
+		}
+	}
+	for i := ref + 1; ref >= 0 && i < len(moduli); i++ {
+		if residues[i].Sign() == 0 {
+			continue
+		}
+		same, err := montgomerySameSign(curve, twist, victim, residues[ref], moduli[ref], residues[i], moduli[i])
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			residues[i].Sub(moduli[i], residues[i]).Mod(residues[i], moduli[i])
+		}
+	}
+	k, r, err := CRT(residues, moduli)
+	if err != nil {
+		return nil, err
+	}
+
+	// The victim's public key lifts to Q = dG or Q = -dG, and d is
+	// k + mr or -k + mr. Either way, Q - kG or Q + kG is a multiple
+	// of rG by some m in [-N/r, N/r].
+	var (
+		w = curve.Weierstrass()
+		v = new(big.Int).ModSqrt(curve.rhs(pub), curve.P)
+	)
+	if v == nil {
+		return nil, fmt.Errorf("public key isn't on the curve")
+	}
+	var (
+		q     = curve.ToWeierstrass(pub, v)
+		g     = w.ScalarBaseMult(r)
+		b     = new(big.Int).Div(curve.N, r)
+		a     = new(big.Int).Neg(b)
+		start = []*big.Int{k, new(big.Int).Neg(k)}
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan *big.Int, len(start))
+	for _, s := range start {
+		go func(s *big.Int) {
+			sG := w.ScalarBaseMult(new(big.Int).Abs(s))
+			if s.Sign() > 0 {
+				sG = w.Neg(sG)
+			}
+			m, err := GroupKangaroo(ctx, w.Group(), g, w.Add(q, sG), a, b, nil)
+			if err != nil {
+				results <- nil
+				return
+			}
+			cancel()
+			m.Mul(m, r).Add(m, s)
+			results <- m.Mod(m, curve.N)
+		}(s)
+	}
+	for range start {
+		if d := <-results; d != nil {
+			return d, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no key found")
+}
+
+// montgomeryPointOfOrder returns the u coordinate of a random point
+// on the twist whose order is the product of primes. The primes must
+// divide the twist order.
+func montgomeryPointOfOrder(c *MontgomeryCurve, twist *big.Int, primes ...*big.Int) (*big.Int, error) {
+	var (
+		e    = new(big.Int).Set(twist)
+		prod = big.NewInt(1)
+		m    = new(big.Int)
+	)
+	for _, r := range primes {
+		for {
+			q, rem := new(big.Int).QuoRem(e, r, m)
+			if rem.Sign() != 0 {
+				break
+			}
+			e = q
+		}
+		prod.Mul(prod, r)
+	}
+
+	for {
+		u, err := rand.Int(rand.Reader, c.P)
+		if err != nil {
+			return nil, err
+		}
+		if c.IsOnCurve(u) {
+			continue
+		}
+		u = c.Ladder(u, e)
+
+		// Trim each prime's part of the order down to r.
+		for _, r := range primes {
+			rest := new(big.Int).Div(prod, r)
+			for c.Ladder(c.Ladder(u, rest), r).Sign() != 0 {
+				u = c.Ladder(u, r)
+			}
+		}
+
+		ok := true
+		for _, r := range primes {
+			if c.Ladder(u, new(big.Int).Div(prod, r)).Sign() == 0 {
+				ok = false
+			}
+		}
+		if ok {
+			return u, nil
+		}
+	}
+}
+
+// montgomeryMultiples returns a function that takes k = 0, 1, 2, ...
+// in turn and returns the bytes of u(kQ), where u is the u coordinate
+// of Q. It steps through the multiples of Q with differential
+// addition, which only needs u coordinates.
+func montgomeryMultiples(c *MontgomeryCurve, u *big.Int) func(k *big.Int) []byte {
+	var (
+		up = new(big.Int).Add(u, big1) // u + 1
+		um = new(big.Int).Sub(u, big1) // u - 1
+
+		// (x0 : z0) is (k-1)Q and (x1 : z1) is kQ.
+		x0, z0 = big.NewInt(1), new(big.Int)
+		x1, z1 = new(big.Int).Set(u), big.NewInt(1)
+
+		s, d = new(big.Int), new(big.Int)
+		aff  = new(big.Int)
+	)
+	return func(k *big.Int) []byte {
+		switch {
+		case k.Sign() == 0:
+			return c.Bytes(new(big.Int))
+		case k.Cmp(big1) == 0:
+		case k.Cmp(big2) == 0:
+			x0, z0, x1, z1 = x1, z1, c.Ladder(u, big2), big.NewInt(1)
+		default:
+			// kQ = (k-1)Q + Q, given (k-1)Q - Q = (k-2)Q.
+			d.Sub(x1, z1).Mul(d, up)
+			s.Add(x1, z1).Mul(s, um)
+			x2 := new(big.Int).Add(d, s)
+			x2.Mul(x2, x2).Mul(x2, z0).Mod(x2, c.P)
+			z2 := new(big.Int).Sub(d, s)
+			z2.Mul(z2, z2).Mul(z2, x0).Mod(z2, c.P)
+			x0, z0, x1, z1 = x1, z1, x2, z2
+		}
+		if z1.Sign() == 0 {
+			aff.SetInt64(0)
+		} else {
+			aff.ModInverse(z1, c.P).Mul(aff, x1).Mod(aff, c.P)
+		}
+		return c.Bytes(aff)
+	}
+}
+
+// montgomerySameSign reports whether the key is k0 mod r0 and ki mod
+// ri with the same sign, or with opposite signs. It sends a point of
+// order r0*ri and checks which combination matches the MAC.
+func montgomerySameSign(c *MontgomeryCurve, twist *big.Int, victim KeyExchangeOracle, k0, r0, ki, ri *big.Int) (bool, error) {
+	u, err := montgomeryPointOfOrder(c, twist, r0, ri)
+	if err != nil {
+		return false, err
+	}
+	msg, mac, err := victim.Exchange(c.Bytes(u))
+	if err != nil {
+		return false, err
+	}
+
+	for _, same := range []bool{true, false} {
+		k := new(big.Int).Set(ki)
+		if !same {
+			k.Sub(ri, k)
+		}
+		x, _, err := CRT([]*big.Int{k0, k}, []*big.Int{r0, ri})
+		if err != nil {
+			return false, err
+		}
+		if hmac.Equal(montgomeryMAC(c, c.Ladder(u, x), msg), mac) {
+			return same, nil
+		}
+	}
+	return false, fmt.Errorf("no sign matches mod %v", new(big.Int).Mul(r0, ri))
+}
+
+// ECDSAPublicKey represents an ECDSA public key. The curve is part
+// of the key, base point included.
+type ECDSAPublicKey struct {
+	Curve *Curve
+	Q     *Point
+}
+
+// ECDSAPrivateKey represents an ECDSA private key.
+type ECDSAPrivateKey struct {
+	ECDSAPublicKey
+	D *big.Int
+}
+
+// ECDSASignature represents an ECDSA signature.
+type ECDSASignature struct {
+	R, S *big.Int
+}
+
+// NewECDSAPrivateKey returns a new ECDSA private key on the curve.
+func NewECDSAPrivateKey(curve *Curve) (*ECDSAPrivateKey, error) {
+	d, err := randScalar(curve.N)
+	if err != nil {
+		return nil, err
+	}
+	return &ECDSAPrivateKey{
+		ECDSAPublicKey: ECDSAPublicKey{Curve: curve, Q: curve.ScalarBaseMult(d)},
+		D:              d,
+	}, nil
+}
+
+// randScalar returns a random integer in [1, n).
+func randScalar(n *big.Int) (*big.Int, error) {
+	d, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big1))
+	if err != nil {
+		return nil, err
+	}
+	return d.Add(d, big1), nil
+}
+
+// Public returns the public half of k.
+func (k *ECDSAPrivateKey) Public() *ECDSAPublicKey {
+	return &k.ECDSAPublicKey
+}
+
+// ecdsaHash returns the SHA-256 hash of msg as an integer, truncated
+// to the bit length of n.
+func ecdsaHash(msg []byte, n *big.Int) *big.Int {
+	sum := sha256.Sum256(msg)
+	e := new(big.Int).SetBytes(sum[:])
+	if excess := len(sum)*8 - n.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	return e
+}
+
+// Sign returns an ECDSA signature of the SHA-256 hash of msg.
+func (k *ECDSAPrivateKey) Sign(msg []byte) (*ECDSASignature, error) {
+	var (
+		c = k.Curve
+		e = ecdsaHash(msg, c.N)
+	)
+	for {
+		nonce, err := randScalar(c.N)
+		if err != nil {
+			return nil, err
+		}
+		sig := k.sign(e, nonce)
+		if sig != nil {
+			return sig, nil
+		}
+	}
+}
+
+// sign returns the signature of the hash e with the given nonce, or
+// nil if the nonce is unusable.
+func (k *ECDSAPrivateKey) sign(e, nonce *big.Int) *ECDSASignature {
+	c := k.Curve
+	r := new(big.Int).Mod(c.ScalarBaseMult(nonce).X, c.N)
+	if r.Sign() == 0 {
+		return nil
+	}
+	s := new(big.Int).Mul(r, k.D)
+	s.Add(s, e).Mul(s, new(big.Int).ModInverse(nonce, c.N)).Mod(s, c.N)
+	if s.Sign() == 0 {
+		return nil
+	}
+	return &ECDSASignature{R: r, S: s}
+}
+
+// MarshalSignature encodes sig as r || s, each as long as the curve's
+// order.
+func (k *ECDSAPublicKey) MarshalSignature(sig *ECDSASignature) []byte {
+	size := (k.Curve.N.BitLen() + 7) / 8
+	b := make([]byte, 2*size)
+	sig.R.FillBytes(b[:size])
+	sig.S.FillBytes(b[size:])
+	return b
+}
+
+// ParseSignature decodes a signature from MarshalSignature.
+func (k *ECDSAPublicKey) ParseSignature(b []byte) (*ECDSASignature, error) {
+	size := (k.Curve.N.BitLen() + 7) / 8
+	if len(b) != 2*size {
+		return nil, fmt.Errorf("invalid signature length %d", len(b))
+	}
+	return &ECDSASignature{
+		R: new(big.Int).SetBytes(b[:size]),
+		S: new(big.Int).SetBytes(b[size:]),
+	}, nil
+}
+
+// Verify reports whether sig is a valid signature of msg.
+func (k *ECDSAPublicKey) Verify(msg []byte, sig *ECDSASignature) bool {
+	c := k.Curve
+	if sig.R.Sign() <= 0 || sig.R.Cmp(c.N) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(c.N) >= 0 {
+		return false
+	}
+	var (
+		w  = new(big.Int).ModInverse(sig.S, c.N)
+		u1 = ecdsaHash(msg, c.N)
+		u2 = new(big.Int).Mul(sig.R, w)
+	)
+	u1.Mul(u1, w).Mod(u1, c.N)
+	u2.Mod(u2, c.N)
+
+	p := c.Add(c.ScalarBaseMult(u1), c.ScalarMult(k.Q, u2))
+	if p.IsIdentity() {
+		return false
+	}
+	return new(big.Int).Mod(p.X, c.N).Cmp(sig.R) == 0
+}
+
+// ECDSAForgeKey returns a new key pair that also validates sig over
+// msg, by picking a new base point. With u1 and u2 from verifying
+// sig, and R = u1*G + u2*Q, a random d' gives G' = (u1 + u2*d')^-1 R
+// and Q' = d'G', so that u1*G' + u2*Q' = R.
+func ECDSAForgeKey(pub *ECDSAPublicKey, msg []byte, sig *ECDSASignature) (*ECDSAPrivateKey, error) {
+	c := pub.Curve
+	if !pub.Verify(msg, sig) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	var (
+		w  = new(big.Int).ModInverse(sig.S, c.N)
+		u1 = ecdsaHash(msg, c.N)
+		u2 = new(big.Int).Mul(sig.R, w)
+	)
+	u1.Mul(u1, w).Mod(u1, c.N)
+	u2.Mod(u2, c.N)
+	r := c.Add(c.ScalarBaseMult(u1), c.ScalarMult(pub.Q, u2))
+
+	for {
+		d, err := randScalar(c.N)
+		if err != nil {
+			return nil, err
+		}
+		t := new(big.Int).Mul(u2, d)
+		t.Add(t, u1).Mod(t, c.N)
+		if t.ModInverse(t, c.N) == nil {
+			continue
+		}
+
+		forged := *c
+		forged.G = c.ScalarMult(r, t)
+		return &ECDSAPrivateKey{
+			ECDSAPublicKey: ECDSAPublicKey{Curve: &forged, Q: forged.ScalarBaseMult(d)},
+			D:              d,
+		}, nil
+	}
+}
+
+// RSAForgeKey returns a new RSA key pair, with a modulus the same
+// size as pub's, that also validates sig over msg. It picks primes p
+// and q where p-1 and q-1 are smooth and sig is a generator mod both,
+// so the padded message m has discrete logs ep and eq mod p and q
+// that Pohlig-Hellman finds quickly. The CRT then gives e with
+// sig^e = m mod pq.
+func RSAForgeKey(pub *RSAPublicKey, msg []byte, sig *big.Int) (*RSAPrivateKey, error) {
+	if !pub.Verify(msg, sig) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	var (
+		bits = pub.N.BitLen()
+		m    = pub.Encrypt(sig)
+	)
+
+	// Each log must be invertible mod p-1 or q-1 for e to be
+	// invertible, which makes them odd, so they agree mod 2 too.
+	for {
+		used := map[uint64]bool{2: true}
+		p, ep, err := rsaForgePrime(bits/2, sig, m, used)
+		if err != nil {
+			return nil, err
+		}
+		q, eq, err := rsaForgePrime(bits-bits/2, sig, m, used)
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits || n.Cmp(sig) <= 0 || n.Cmp(m) <= 0 {
+			continue
+		}
+
+		// p-1 and q-1 only share the factor 2, so use (q-1)/2.
+		qm1 := new(big.Int).Sub(q, big1)
+		e, _, err := CRT([]*big.Int{ep, eq}, []*big.Int{new(big.Int).Sub(p, big1), qm1.Rsh(qm1, 1)})
+		if err != nil {
+			return nil, err
+		}
+		return newRSAPrivateKey(p, q, e)
+	}
+}
+
+// rsaForgePrime returns a smooth prime p and the discrete log of m
+// base sig mod p, which is invertible mod p-1.
+func rsaForgePrime(bits int, sig, m *big.Int, used map[uint64]bool) (*big.Int, *big.Int, error) {
+	for {
+		p, factors, err := smoothPrime(bits, sig, used)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := PohligHellman(ModPGroup{P: p}, sig, new(big.Int).Mod(m, p), factors)
+		if err != nil {
+			return nil, nil, err
+		}
+		if new(big.Int).GCD(nil, nil, e, new(big.Int).Sub(p, big1)).Cmp(big1) == 0 {
+			return p, e, nil
+		}
+	}
+}
+
+// smoothPrime returns a prime p of the given size where p-1 is 2
+// times distinct odd primes under 2^24, none of them in used, and
+// g generates the integers mod p. It returns the factors of p-1 and
+// adds them to used.
+func smoothPrime(bits int, g *big.Int, used map[uint64]bool) (*big.Int, []Factor, error) {
+	var (
+		lo = new(big.Int).Lsh(big1, uint(bits-1))
+		hi = new(big.Int).Lsh(big1, uint(bits))
+	)
+	for {
+		var (
+			pm1     = big.NewInt(2)
+			factors = []Factor{{P: big.NewInt(2), K: 1}}
+			mine    = make(map[uint64]bool)
+		)
+		// Fill most of p-1 with 16-bit primes.
+		for bits-pm1.BitLen() > 24 {
+			r, err := rand.Prime(rand.Reader, 16)
+			if err != nil {
+				return nil, nil, err
+			}
+			if used[r.Uint64()] || mine[r.Uint64()] {
+				continue
+			}
+			mine[r.Uint64()] = true
+			pm1.Mul(pm1, r)
+			factors = append(factors, Factor{P: r, K: 1})
+		}
+
+		// Then pick the last prime r so that pm1*r+1 is the right size.
+		// It's worth a lot of tries before starting over.
+		var (
+			rlo = new(big.Int).Div(lo, pm1)
+			rhi = new(big.Int).Div(hi, pm1)
+		)
+		rlo.Add(rlo, big1)
+		rhi.Sub(rhi, rlo)
+		for try := 0; try < 10000; try++ {
+			r, err := rand.Int(rand.Reader, rhi)
+			if err != nil {
+				return nil, nil, err
+			}
+			r.Add(r, rlo)
+			if r.Bit(0) == 0 || used[r.Uint64()] || mine[r.Uint64()] || !r.ProbablyPrime(20) {
+				continue
+			}
+			p := new(big.Int).Mul(pm1, r)
+			p.Add(p, big1)
+			if p.BitLen() != bits || !p.ProbablyPrime(20) {
+				continue
+			}
+
+			all := append(factors[:len(factors):len(factors)], Factor{P: r, K: 1})
+			if !isGenerator(g, p, all) {
+				continue
+			}
+
+			used[r.Uint64()] = true
+			for r := range mine {
+				used[r] = true
+			}
+			return p, all, nil
+		}
+	}
+}
+
+// isGenerator reports whether g generates the integers mod p, where
+// factors are the prime factors of p-1.
+func isGenerator(g, p *big.Int, factors []Factor) bool {
+	var (
+		pm1 = new(big.Int).Sub(p, big1)
+		e   = new(big.Int)
+	)
+	for _, f := range factors {
+		if new(big.Int).Exp(g, e.Div(pm1, f.P), p).Cmp(big1) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ECDSABiasedSigner signs messages with ECDSA, but with nonces whose
+// low bits are always zero.
+type ECDSABiasedSigner struct {
+	key  *ECDSAPrivateKey
+	bits uint
+}
+
+// NewECDSABiasedSigner returns a new ECDSABiasedSigner with a random
+// key, whose nonces have their low bits zeroed.
+func NewECDSABiasedSigner(curve *Curve, bits uint) (*ECDSABiasedSigner, error) {
+	key, err := NewECDSAPrivateKey(curve)
+	if err != nil {
+		return nil, err
+	}
+	return &ECDSABiasedSigner{key: key, bits: bits}, nil
+}
+
+// PublicKey returns the signer's public key.
+func (s *ECDSABiasedSigner) PublicKey() *ECDSAPublicKey {
+	return s.key.Public()
+}
+
+// Sign returns an ECDSA signature of the SHA-256 hash of msg, encoded
+// with MarshalSignature.
+func (s *ECDSABiasedSigner) Sign(msg []byte) ([]byte, error) {
+	var (
+		c = s.key.Curve
+		e = ecdsaHash(msg, c.N)
+	)
+	for {
+		nonce, err := randScalar(c.N)
+		if err != nil {
+			return nil, err
+		}
+		nonce.Rsh(nonce, s.bits).Lsh(nonce, s.bits)
+		if nonce.Sign() == 0 {
+			continue
+		}
+		if sig := s.key.sign(e, nonce); sig != nil {
+			return s.key.MarshalSignature(sig), nil
+		}
+	}
+}
+
+// Verify reports whether sig, encoded with MarshalSignature, is a
+// valid signature of msg.
+func (s *ECDSABiasedSigner) Verify(msg, sig []byte) (bool, error) {
+	parsed, err := s.key.ParseSignature(sig)
+	if err != nil {
+		return false, err
+	}
+	return s.key.Verify(msg, parsed), nil
+}
+
+// ECDSABiasedNonceRecoverKey recovers the private key for pub from n
+// signatures of random messages, whose nonces k have the given number
+// of low bits zeroed. The signer must encode signatures with
+// MarshalSignature. That makes each signature a hidden number problem:
+// with t = r / (s * 2^bits) and u = H(m) / (-s * 2^bits) mod N,
+// d*t - u = k / 2^bits mod N, which is small. The lattice spanned by
+// the rows
+//
+//	N  0  ... 0  0   0
+//	0  N  ... 0  0   0
+//	...
+//	t1 t2 ... tn ct  0
+//	u1 u2 ... un 0   cu
+//
+// with ct = 1/2^bits and cu = N/2^bits, has a short vector ending in
+// cu, and -d*ct comes right before it.
+func ECDSABiasedNonceRecoverKey(pub *ECDSAPublicKey, bits uint, signer SignOracle, n int) (*big.Int, error) {
+	var (
+		c     = pub.Curve
+		shift = new(big.Int).Lsh(big1, bits)
+		basis = make([][]*big.Rat, n+2)
+	)
+	for i := range basis {
+		basis[i] = make([]*big.Rat, n+2)
+		for j := range basis[i] {
+			basis[i][j] = new(big.Rat)
+		}
+	}
+
+	msg := make([]byte, 16)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(msg); err != nil {
+			return nil, err
+		}
+		b, err := signer.Sign(msg)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := pub.ParseSignature(b)
+		if err != nil {
+			return nil, err
+		}
+
+		// sinv = 1 / (s * 2^bits) mod N
+		sinv := new(big.Int).Mul(sig.S, shift)
+		sinv.ModInverse(sinv.Mod(sinv, c.N), c.N)
+
+		t := new(big.Int).Mul(sig.R, sinv)
+		u := new(big.Int).Mul(ecdsaHash(msg, c.N), sinv)
+		u.Neg(u)
+
+		basis[i][i].SetInt(c.N)
+		basis[n][i].SetInt(t.Mod(t, c.N))
+		basis[n+1][i].SetInt(u.Mod(u, c.N))
+	}
+	ct := new(big.Rat).SetFrac(big1, shift)
+	cu := new(big.Rat).SetFrac(c.N, shift)
+	basis[n][n].Set(ct)
+	basis[n+1][n+1].Set(cu)
+
+	reduced, err := LLL(basis, big.NewRat(99, 100))
+	if err != nil {
+		return nil, err
+	}
+
+	negcu := new(big.Rat).Neg(cu)
+	for _, row := range reduced {
+		d := new(big.Rat).Mul(row[n], new(big.Rat).SetInt(shift))
+		switch {
+		case row[n+1].Cmp(cu) == 0:
+		case row[n+1].Cmp(negcu) == 0: // LLL doesn't care about signs.
+			d.Neg(d)
+		default:
+			continue
+		}
+		if !d.IsInt() {
+			continue
+		}
+		key := new(big.Int).Neg(d.Num())
+		key.Mod(key, c.N)
+		if c.ScalarBaseMult(key).Equal(pub.Q) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no key in the reduced basis")
+}
+
+// GCM is AES-GCM, written out by hand so that the attacks can see
+// inside. Its output matches crypto/cipher's GCM.
+type GCM struct {
+	b       cipher.Block
+	h       GF128
+	tagSize int
+}
+
+// GCMTagSize is the size of a GCM tag in bytes.
+const GCMTagSize = 16
+
+// NewGCM returns a new GCM.
+func NewGCM(key []byte) (*GCM, error) {
+	return NewGCMWithTagSize(key, GCMTagSize)
+}
+
+// NewGCMWithTagSize returns a new GCM whose tags are truncated to
+// tagSize bytes. Unlike crypto/cipher, it allows any size from 1 to 16.
+func NewGCMWithTagSize(key []byte, tagSize int) (*GCM, error) {
+	if tagSize < 1 || tagSize > GCMTagSize {
+		return nil, fmt.Errorf("invalid tag size %d", tagSize)
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	h := make([]byte, 16)
+	b.Encrypt(h, h)
+	return &GCM{b: b, h: GF128FromBytes(h), tagSize: tagSize}, nil
+}
+
+// H returns the GHASH authentication key, which is the encryption of
+// the zero block.
+func (g *GCM) H() GF128 {
+	return g.h
+}
+
+// Seal encrypts and authenticates pt, authenticates ad, and returns
+// the ciphertext with the tag appended.
+func (g *GCM) Seal(nonce, pt, ad []byte) []byte {
+	j0 := g.j0(nonce)
+	ct := make([]byte, len(pt), len(pt)+g.tagSize)
+	g.ctr(ct, pt, j0)
+	return append(ct, g.tag(j0, ad, ct)...)
+}
+
+// Open authenticates ct and ad, and returns the decrypted ciphertext.
+func (g *GCM) Open(nonce, ct, ad []byte) ([]byte, error) {
+	if len(ct) < g.tagSize {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	var (
+		j0  = g.j0(nonce)
+		tag = ct[len(ct)-g.tagSize:]
+	)
+	ct = ct[:len(ct)-g.tagSize]
+	if !hmac.Equal(tag, g.tag(j0, ad, ct)) {
+		return nil, fmt.Errorf("invalid tag")
+	}
+	pt := make([]byte, len(ct))
+	g.ctr(pt, ct, j0)
+	return pt, nil
+}
+
+// j0 returns the pre-counter block for a nonce.
+func (g *GCM) j0(nonce []byte) []byte {
+	if len(nonce) == 12 {
+		j0 := make([]byte, 16)
+		copy(j0, nonce)
+		j0[15] = 1
+		return j0
+	}
+	coeffs := GCMTagCoefficients(nil, nonce)
+	coeffs[len(coeffs)-1] = GF128{lo: uint64(len(nonce)) * 8}
+	return GHASH(g.h, coeffs).Bytes()
+}
+
+// ctr XORs src with the keystream that starts at inc32(j0).
+func (g *GCM) ctr(dst, src, j0 []byte) {
+	var (
+		ctr = make([]byte, 16)
+		ks  = make([]byte, 16)
+	)
+	copy(ctr, j0)
+	for i := 0; i < len(src); i += 16 {
+		binary.BigEndian.PutUint32(ctr[12:], binary.BigEndian.Uint32(ctr[12:])+1)
+		g.b.Encrypt(ks, ctr)
+		for j := i; j < len(src) && j < i+16; j++ {
+			dst[j] = src[j] ^ ks[j-i]
+		}
+	}
+}
+
+// tag returns the tag for ad and ct, truncated to the tag size.
+func (g *GCM) tag(j0, ad, ct []byte) []byte {
+	s := make([]byte, 16)
+	g.b.Encrypt(s, j0)
+	return GHASH(g.h, GCMTagCoefficients(ad, ct)).Add(GF128FromBytes(s)).Bytes()[:g.tagSize]
+}
+
+// GCMTagCoefficients returns the blocks that GHASH authenticates: ad
+// and ct, each zero-padded to whole blocks, and then a block with
+// their lengths in bits. The tag is the polynomial with these
+// coefficients, from the highest degree down to H^1, evaluated at H,
+// plus the encrypted pre-counter block.
+func GCMTagCoefficients(ad, ct []byte) []GF128 {
+	var res []GF128
+	for _, b := range [][]byte{ad, ct} {
+		for i := 0; i < len(b); i += 16 {
+			block := make([]byte, 16)
+			copy(block, b[i:])
+			res = append(res, GF128FromBytes(block))
+		}
+	}
+	return append(res, GF128{hi: uint64(len(ad)) * 8, lo: uint64(len(ct)) * 8})
+}
+
+// GHASH returns c[0]*h^n + c[1]*h^(n-1) + ... + c[n-1]*h, where n is
+// the number of coefficients.
+func GHASH(h GF128, coeffs []GF128) GF128 {
+	var y GF128
+	for _, c := range coeffs {
+		y = y.Add(c).Mul(h)
+	}
+	return y
+}
+
+// GCMMessage is a message sealed with GCM: its associated data,
+// ciphertext and tag.
+type GCMMessage struct {
+	AD, CT, Tag []byte
+}
+
+// gcmTagPoly returns the polynomial whose value at H is the message's
+// tag minus the mask E(J0).
+func gcmTagPoly(m GCMMessage) GF128Poly {
+	var (
+		coeffs = GCMTagCoefficients(m.AD, m.CT)
+		p      = make(GF128Poly, len(coeffs)+1)
+	)
+	for i, c := range coeffs {
+		p[len(coeffs)-i] = c
+	}
+	p[0] = GF128FromBytes(m.Tag)
+	return p.trim()
+}
+
+// GCMNonceReuseRecoverH returns the candidates for the GHASH key of
+// messages sealed under one key and one nonce. The nonce makes the
+// mask E(J0) the same for every message, so for any two, the
+// difference of their tag polynomials has H as a root. Each extra
+// message narrows the candidates down further.
+func GCMNonceReuseRecoverH(msgs []GCMMessage) ([]GF128, error) {
+	if len(msgs) < 2 {
+		return nil, fmt.Errorf("need at least two messages")
+	}
+	var (
+		first = gcmTagPoly(msgs[0])
+		cands map[GF128]bool
+	)
+	for _, m := range msgs[1:] {
+		roots, err := first.Add(gcmTagPoly(m)).Roots()
+		if err != nil {
+			return nil, err
+		}
+		next := make(map[GF128]bool)
+		for _, r := range roots {
+			if cands == nil || cands[r] {
+				next[r] = true
+			}
+		}
+		cands = next
+	}
+
+	res := make([]GF128, 0, len(cands))
+	for h := range cands {
+		res = append(res, h)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+	return res, nil
+}
+
+// GCMForgeTag returns a valid tag for ad and ct, given the GHASH key
+// and another message sealed with the same nonce.
+func GCMForgeTag(h GF128, known GCMMessage, ad, ct []byte) []byte {
+	mask := GF128FromBytes(known.Tag).Add(GHASH(h, GCMTagCoefficients(known.AD, known.CT)))
+	return GHASH(h, GCMTagCoefficients(ad, ct)).Add(mask).Bytes()
+}
+
+// GCMTruncatedMACVictim seals messages with GCM under a fixed key and
+// nonce, but with a short tag, and tells anyone who asks whether a
+// ciphertext is authentic.
+type GCMTruncatedMACVictim struct {
+	gcm   *GCM
+	nonce []byte
+}
+
+// NewGCMTruncatedMACVictim returns a new GCMTruncatedMACVictim with a
+// random key and nonce, and tags of tagSize bytes.
+func NewGCMTruncatedMACVictim(tagSize int) (*GCMTruncatedMACVictim, error) {
+	var (
+		key   = make([]byte, 16)
+		nonce = make([]byte, 12)
+	)
+	for _, b := range [][]byte{key, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	g, err := NewGCMWithTagSize(key, tagSize)
+	if err != nil {
+		return nil, err
+	}
+	return &GCMTruncatedMACVictim{gcm: g, nonce: nonce}, nil
+}
+
+// TagSize returns the size of the victim's tags in bytes.
+func (v *GCMTruncatedMACVictim) TagSize() int {
+	return v.gcm.tagSize
+}
+
+// Message returns a random message of the given number of blocks,
+// sealed, with the tag appended.
+func (v *GCMTruncatedMACVictim) Message(blocks int) ([]byte, error) {
+	pt := make([]byte, 16*blocks)
+	if _, err := rand.Read(pt); err != nil {
+		return nil, err
+	}
+	return v.gcm.Seal(v.nonce, pt, nil), nil
+}
+
+// Verify reports whether sealed, a ciphertext with its tag appended,
+// is authentic.
+func (v *GCMTruncatedMACVictim) Verify(sealed []byte) bool {
+	_, err := v.gcm.Open(v.nonce, sealed, nil)
+	return err == nil
+}
+
+// Valid is Verify, so the victim can be used as a ValidityOracle.
+func (v *GCMTruncatedMACVictim) Valid(sealed []byte) (bool, error) {
+	return v.Verify(sealed), nil
+}
+
+// GCMTruncatedMACRecoverH recovers a GHASH key, given one message
+// sealed with tags of tagSize bytes, and an oracle that reports
+// whether a sealed message is authentic. The message must be whole blocks, and the more of
+// them, the fewer forgery attempts it takes. If progress isn't nil,
+// it's called with the number of attempts so far and the number of
+// bits of H known each time a forgery succeeds. It also returns the
+// total number of attempts.
+//
+// Squaring is linear, so changing the blocks that multiply H^(2^i) by
+// e_i changes the tag by Ad*h, where Ad is the sum of Mc(e_i)*Ms^i.
+// Choosing the e_i to zero out some rows of Ad makes a forgery more
+// likely, and each forgery that verifies says that the remaining tag
+// rows of Ad are orthogonal to h. Those rows restrict H to a smaller
+// subspace, which in turn lets the next forgery zero out more rows.
+func GCMTruncatedMACRecoverH(oracle ValidityOracle, tagSize int, sealed []byte, progress func(attempts, bits int)) (GF128, int, error) {
+	var (
+		tagBits = 8 * tagSize
+		n       = len(sealed) - tagSize
+	)
+	if n <= 0 || n%16 != 0 {
+		return GF128{}, 0, fmt.Errorf("message isn't whole blocks")
+	}
+
+	// With b blocks of ciphertext, block b+1-2^i multiplies H^(2^i),
+	// since the length block multiplies H.
+	var (
+		blocks = n / 16
+		k      = 0
+	)
+	for 1<<(k+1) <= blocks+1 {
+		k++
+	}
+	sq := make([]*BitMatrix, k+1)
+	sq[0] = IdentityBitMatrix(128)
+	for i := 1; i <= k; i++ {
+		sq[i] = GF128SquareMatrix().Mul(sq[i-1])
+	}
+
+	var (
+		eqs      = NewBitMatrix(0, 128) // Rows orthogonal to h.
+		x        = IdentityBitMatrix(128)
+		attempts int
+		forged   = append([]byte(nil), sealed...)
+		errs     = make([]GF128, k+1)
+	)
+	for x.Cols() > 1 {
+		// Zero out as many rows as we can while leaving some choice.
+		z := (128*k - 1) / x.Cols()
+		if z > tagBits-1 {
+			z = tagBits - 1
+		}
+		kernel := gcmTruncatedDependencies(sq, x, z).Kernel()
+
+		for {
+			e, err := randomKernelVector(kernel)
+			if err != nil {
+				return GF128{}, attempts, err
+			}
+			for i := 1; i <= k; i++ {
+				errs[i] = GF128{}
+				for b := 0; b < 128; b++ {
+					if e.Get(0, 128*(i-1)+b) == 1 {
+						errs[i] = errs[i].Add(gf128Monomial(b))
+					}
+				}
+			}
+			gcmApplyErrors(forged, blocks, errs)
+			ok, err := oracle.Valid(forged)
+			gcmApplyErrors(forged, blocks, errs) // Undo them.
+			if err != nil {
+				return GF128{}, attempts, err
+			}
+			attempts++
+			if ok {
+				break
+			}
+		}
+
+		ad := NewBitMatrix(128, 128)
+		for i := 1; i <= k; i++ {
+			ad = ad.Add(GF128MulMatrix(errs[i]).Mul(sq[i]))
+		}
+		eqs = eqs.Stack(ad.Slice(z, tagBits))
+		x = eqs.Kernel().Transpose()
+		if progress != nil {
+			progress(attempts, 128-x.Cols())
+		}
+	}
+	if x.Cols() == 0 {
+		return GF128{}, attempts, fmt.Errorf("no solution for H")
+	}
+	return GF128FromVector(x), attempts, nil
+}
+
+// gcmApplyErrors XORs errs[i] into the block of a message of blocks
+// blocks that multiplies H^(2^i), for each i from 1.
+func gcmApplyErrors(msg []byte, blocks int, errs []GF128) {
+	for i := 1; i < len(errs); i++ {
+		off := 16 * (blocks + 1 - 1<<i)
+		for j, c := range errs[i].Bytes() {
+			msg[off+j] ^= c
+		}
+	}
+}
+
+// gcmTruncatedDependencies returns the matrix T that maps the bits of
+// the errors e_1, ..., e_k to the first z rows of Ad*X, so that the
+// errors in its kernel zero out those rows.
+func gcmTruncatedDependencies(sq []*BitMatrix, x *BitMatrix, z int) *BitMatrix {
+	var (
+		dim = x.Cols()
+		t   = NewBitMatrix(z*dim, 128*(len(sq)-1))
+	)
+	for i := 1; i < len(sq); i++ {
+		y := sq[i].Mul(x)
+		for c := 0; c < dim; c++ {
+			// Setting bit b of e_i adds x^b times column c of Ms^i*X to
+			// column c of Ad*X.
+			col := gf128Column(y, c)
+			for b := 0; b < 128; b++ {
+				for r := 0; r < z; r++ {
+					if col.Bit(r) == 1 {
+						t.Set(r*dim+c, 128*(i-1)+b, 1)
+					}
+				}
+				col = col.mulX()
+			}
+		}
+	}
+	return t
+}
+
+// randomKernelVector returns a random nonzero combination of the rows
+// of kernel, as a row vector.
+func randomKernelVector(kernel *BitMatrix) (*BitMatrix, error) {
+	coeffs := make([]byte, (kernel.Rows()+7)/8)
+	for {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, err
+		}
+		var (
+			v    = NewBitMatrix(1, kernel.Cols())
+			zero = true
+		)
+		for i := 0; i < kernel.Rows(); i++ {
+			if coeffs[i/8]>>(i%8)&1 == 1 {
+				xorWords(v.data[0], kernel.data[i])
+				zero = false
+			}
+		}
+		if !zero {
+			return v, nil
+		}
+	}
+}
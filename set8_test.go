@@ -0,0 +1,585 @@
+package cryptopals
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestChallenge57(t *testing.T) {
+	t.Parallel()
+	group := DHGroup57()
+
+	victim, err := NewDHMACVictim(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &OracleMeter{}
+	got, err := DHSubgroupRecoverKey(group, m.KeyExchange(victim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d queries", m.Queries())
+	if y := new(big.Int).Exp(group.G, got, group.P); y.Cmp(victim.PublicKey()) != 0 {
+		t.Errorf("got %v, which doesn't match the public key", got)
+	}
+}
+
+func TestKangaroo(t *testing.T) {
+	t.Parallel()
+	group := DHGroup58()
+
+	// Any function of the element works as a jump function.
+	cases := map[string]KangarooJump{
+		"default": nil,
+		"custom": func(y *big.Int, k int) int {
+			return int(y.Bits()[len(y.Bits())-1] % big.Word(k))
+		},
+	}
+
+	for name, jump := range cases {
+		jump := jump
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				a = big.NewInt(1000)
+				b = big.NewInt(1000 + 1<<20)
+				x = big.NewInt(1000 + 705485)
+				y = new(big.Int).Exp(group.G, x, group.P)
+			)
+
+			got, err := Kangaroo(context.Background(), group.G, y, group.P, a, b, jump)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Cmp(x) != 0 {
+				t.Errorf("got %v, want %v", got, x)
+			}
+		})
+	}
+}
+
+func TestKangaroo_Cancel(t *testing.T) {
+	t.Parallel()
+	var (
+		group = DHGroup58()
+		y     = new(big.Int).Exp(group.G, big.NewInt(12345), group.P)
+		b     = new(big.Int).Lsh(big1, 60)
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Kangaroo(ctx, group.G, y, group.P, new(big.Int), b, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestChallenge58(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping 40-bit kangaroo search in short mode")
+	}
+	group := DHGroup58()
+
+	// The challenge's warm-up: a 40-bit interval.
+	var (
+		x = mustBigInt("359579637328")
+		y = new(big.Int).Exp(group.G, x, group.P)
+		b = new(big.Int).Lsh(big1, 40)
+	)
+	got, err := Kangaroo(context.Background(), group.G, y, group.P, new(big.Int), b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(x) != 0 {
+		t.Errorf("got %v, want %v", got, x)
+	}
+
+	victim, err := NewDHMACVictim(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = DHKangarooRecoverKey(context.Background(), group, victim.PublicKey(), victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y := new(big.Int).Exp(group.G, got, group.P); y.Cmp(victim.PublicKey()) != 0 {
+		t.Errorf("got %v, which doesn't match the public key", got)
+	}
+}
+
+func TestChallenge59(t *testing.T) {
+	t.Parallel()
+	var (
+		curve   = Curve59()
+		invalid = Challenge59InvalidCurves()
+	)
+
+	victim, err := NewECDHMACVictim(curve, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ECInvalidCurveRecoverKey(curve, invalid, victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := curve.ScalarBaseMult(got); !p.Equal(victim.PublicKey()) {
+		t.Errorf("got %v, which doesn't match the public key", got)
+	}
+
+	// Validating public keys stops the attack.
+	victim, err = NewECDHMACVictim(curve, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ECInvalidCurveRecoverKey(curve, invalid, victim); err == nil {
+		t.Error("attack succeeded against a validating victim")
+	}
+}
+
+func TestChallenge60(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping twist attack in short mode")
+	}
+	curve := Curve60()
+
+	victim, err := NewMontgomeryDHMACVictim(curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ECTwistRecoverKey(context.Background(), curve, victim.PublicKey(), victim, 1<<24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := curve.Ladder(curve.U, got); u.Cmp(victim.PublicKey()) != 0 {
+		t.Errorf("got %v, which doesn't match the public key", got)
+	}
+}
+
+func TestECDSA(t *testing.T) {
+	t.Parallel()
+	msg := []byte("hi mom")
+
+	key, err := NewECDSAPrivateKey(Curve59())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Verify(msg, sig) {
+		t.Error("signature didn't verify")
+	}
+	if key.Verify([]byte("hi dad"), sig) {
+		t.Error("signature verified for the wrong message")
+	}
+
+	bad := &ECDSASignature{R: sig.R, S: new(big.Int).Add(sig.S, big1)}
+	if key.Verify(msg, bad) {
+		t.Error("tampered signature verified")
+	}
+}
+
+func TestChallenge61(t *testing.T) {
+	t.Parallel()
+	msg := []byte("I'll be the first to admit I'm not perfect")
+
+	t.Run("ECDSA", func(t *testing.T) {
+		t.Parallel()
+		key, err := NewECDSAPrivateKey(Curve59())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := key.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forged, err := ECDSAForgeKey(key.Public(), msg, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forged.Q.Equal(key.Q) {
+			t.Error("forged key is the original key")
+		}
+		if !forged.Verify(msg, sig) {
+			t.Error("signature didn't verify under the forged key")
+		}
+
+		// The forged key is a working key pair.
+		sig2, err := forged.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !forged.Verify(msg, sig2) {
+			t.Error("forged key can't sign")
+		}
+	})
+
+	t.Run("RSA", func(t *testing.T) {
+		t.Parallel()
+		key, err := NewRSAPrivateKey(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := key.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forged, err := RSAForgeKey(key.Public(), msg, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forged.N.Cmp(key.N) == 0 {
+			t.Error("forged key is the original key")
+		}
+		if !forged.Verify(msg, sig) {
+			t.Error("signature didn't verify under the forged key")
+		}
+
+		// The forged key is a working key pair.
+		sig2, err := forged.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig2.Cmp(sig) != 0 {
+			t.Errorf("forged key signs as %v, want %v", sig2, sig)
+		}
+	})
+}
+
+func TestChallenge62(t *testing.T) {
+	t.Parallel()
+	signer, err := NewECDSABiasedSigner(Curve59(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub := signer.PublicKey()
+	got, err := ECDSABiasedNonceRecoverKey(pub, 8, signer, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := pub.Curve.ScalarBaseMult(got); !p.Equal(pub.Q) {
+		t.Errorf("got %v, which doesn't match the public key", got)
+	}
+}
+
+func TestGCM(t *testing.T) {
+	t.Parallel()
+	// NIST GCM test cases 1-4 and 6, with AES-128.
+	cases := []struct {
+		key, nonce, pt, ad, ct, tag string
+	}{
+		{
+			key:   "00000000000000000000000000000000",
+			nonce: "000000000000000000000000",
+			tag:   "58e2fccefa7e3061367f1d57a4e7455a",
+		},
+		{
+			key:   "00000000000000000000000000000000",
+			nonce: "000000000000000000000000",
+			pt:    "00000000000000000000000000000000",
+			ct:    "0388dace60b6a392f328c2b971b2fe78",
+			tag:   "ab6e47d42cec13bdf53a67b21257bddf",
+		},
+		{
+			key:   "feffe9928665731c6d6a8f9467308308",
+			nonce: "cafebabefacedbaddecaf888",
+			pt:    "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255",
+			ct:    "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+			tag:   "4d5c2af327cd64a62cf35abd2ba6fab4",
+		},
+		{
+			key:   "feffe9928665731c6d6a8f9467308308",
+			nonce: "cafebabefacedbaddecaf888",
+			pt:    "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+			ad:    "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+			ct:    "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+			tag:   "5bc94fbc3221a5db94fae95ae7121a47",
+		},
+		{
+			key:   "feffe9928665731c6d6a8f9467308308",
+			nonce: "9313225df88406e555909c5aff5269aa6a7a9538534f7da1e4c303d2a318a728c3c0c95156809539fcf0e2429a6b525416aedbf5a0de6a57a637b39b",
+			pt:    "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+			ad:    "feedfacedeadbeeffeedfacedeadbeefabaddad2",
+			ct:    "8ce24998625615b603a033aca13fb894be9112a5c3a211a8ba262a3cca7e2ca701e4a9a4fba43c90ccdcb281d48c7c6fd62875d2aca417034c34aee5",
+			tag:   "619cc5aefffe0bfa462af43c1699d050",
+		},
+	}
+
+	for i, tc := range cases {
+		var (
+			key   = HelperDecodeHex(t, tc.key)
+			nonce = HelperDecodeHex(t, tc.nonce)
+			pt    = HelperDecodeHex(t, tc.pt)
+			ad    = HelperDecodeHex(t, tc.ad)
+			want  = HelperDecodeHex(t, tc.ct+tc.tag)
+		)
+		g, err := NewGCM(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := g.Seal(nonce, pt, ad)
+		if !bytes.Equal(got, want) {
+			t.Errorf("case %d: got %x, want %x", i+1, got, want)
+		}
+		dec, err := g.Open(nonce, got, ad)
+		if err != nil {
+			t.Errorf("case %d: %v", i+1, err)
+		}
+		if !bytes.Equal(dec, pt) {
+			t.Errorf("case %d: got %x, want %x", i+1, dec, pt)
+		}
+	}
+}
+
+func TestGCM_Stdlib(t *testing.T) {
+	t.Parallel()
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	std, err := cipher.NewGCM(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 70; n += 7 {
+		var (
+			nonce = make([]byte, 12)
+			pt    = make([]byte, n)
+			ad    = make([]byte, n/2)
+		)
+		for _, b := range [][]byte{nonce, pt, ad} {
+			if _, err := rand.Read(b); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var (
+			got  = g.Seal(nonce, pt, ad)
+			want = std.Seal(nil, nonce, pt, ad)
+		)
+		if !bytes.Equal(got, want) {
+			t.Errorf("len %d: got %x, want %x", n, got, want)
+		}
+
+		got[0] ^= 1
+		if _, err := g.Open(nonce, got, ad); err == nil {
+			t.Errorf("len %d: opened a tampered ciphertext", n)
+		}
+	}
+}
+
+func TestGCMTagCoefficients(t *testing.T) {
+	t.Parallel()
+	var (
+		key   = make([]byte, 16)
+		nonce = make([]byte, 12)
+		ad    = []byte("some associated data")
+		pt    = []byte("a plaintext that's a few blocks long")
+	)
+	g, err := NewGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := g.Seal(nonce, pt, ad)
+	ct, tag := sealed[:len(pt)], sealed[len(pt):]
+
+	// The tag is GHASH plus a mask that only depends on the nonce, so
+	// the mask is the same for every message.
+	mask := GF128FromBytes(tag).Add(GHASH(g.H(), GCMTagCoefficients(ad, ct)))
+	sealed = g.Seal(nonce, nil, nil)
+	if got := GHASH(g.H(), GCMTagCoefficients(nil, nil)).Add(mask); got != GF128FromBytes(sealed) {
+		t.Errorf("got tag %v, want %x", got, sealed)
+	}
+	if n := len(GCMTagCoefficients(ad, ct)); n != 2+3+1 {
+		t.Errorf("got %d coefficients, want 6", n)
+	}
+}
+
+func TestChallenge63(t *testing.T) {
+	t.Parallel()
+	var (
+		key   = make([]byte, 16)
+		nonce = make([]byte, 12)
+	)
+	for _, b := range [][]byte{key, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := NewGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same nonce, over and over.
+	var msgs []GCMMessage
+	for _, s := range []string{
+		"attack at dawn",
+		"attack at dusk, unless it's raining",
+		"never mind, stay home",
+	} {
+		var (
+			ad     = []byte("header")
+			sealed = g.Seal(nonce, []byte(s), ad)
+			n      = len(sealed) - GCMTagSize
+		)
+		msgs = append(msgs, GCMMessage{AD: ad, CT: sealed[:n], Tag: sealed[n:]})
+	}
+
+	cands, err := GCMNonceReuseRecoverH(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 1 {
+		t.Fatalf("got %d candidates, want 1", len(cands))
+	}
+	if cands[0] != g.H() {
+		t.Errorf("got H = %v, want %v", cands[0], g.H())
+	}
+
+	// Forge a tag for a ciphertext we made up.
+	var (
+		ad = []byte("a different header")
+		ct = []byte("anything at all, really")
+	)
+	tag := GCMForgeTag(cands[0], msgs[0], ad, ct)
+	if _, err := g.Open(nonce, append(ct, tag...), ad); err != nil {
+		t.Errorf("forgery failed: %v", err)
+	}
+}
+
+func TestChallenge64(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		tagSize int
+		blocks  int
+		linear  bool
+	}{
+		// 16-bit tags and 2^8 blocks work the same way as the
+		// challenge, against the real victim, in no time.
+		{"16-bit", 2, 1 << 8, false},
+		// The challenge uses 32-bit tags. Whatever the message
+		// length, the real victim would do about 2^33 block
+		// multiplications checking forgeries, so a linear model of
+		// it answers instead.
+		{"32-bit", 4, 1 << 16, true},
+	}
+	for _, tc := range cases {
+		if tc.tagSize > 2 && testing.Short() {
+			t.Logf("%s: skipping in short mode", tc.name)
+			continue
+		}
+
+		victim, err := NewGCMTruncatedMACVictim(tc.tagSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := victim.Message(tc.blocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var oracle ValidityOracle = victim
+		if tc.linear {
+			oracle = helperGCMLinearOracle(t, victim, sealed)
+		}
+
+		var (
+			last  int
+			meter = new(OracleMeter)
+		)
+		h, attempts, err := GCMTruncatedMACRecoverH(meter.Validity(oracle), victim.TagSize(), sealed, func(attempts, bits int) {
+			if bits < last {
+				t.Errorf("%s: went from %d to %d known bits", tc.name, last, bits)
+			}
+			last = bits
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h != victim.gcm.H() {
+			t.Errorf("%s: got H = %v, want %v", tc.name, h, victim.gcm.H())
+		}
+		if last != 127 {
+			t.Errorf("%s: last progress report had %d known bits, want 127", tc.name, last)
+		}
+		if attempts != meter.Queries() {
+			t.Errorf("%s: got %d attempts, meter counted %d", tc.name, attempts, meter.Queries())
+		}
+		t.Logf("%s: recovered H in %d attempts", tc.name, attempts)
+	}
+}
+
+// helperGCMLinearOracle returns a ValidityOracle that answers like
+// victim for changes to the ciphertext blocks of sealed. GHASH is
+// linear, so a change only verifies if the changed blocks' terms add
+// up to nothing in the truncated tag, and only those terms need
+// working out. Forgeries it accepts are checked against the victim.
+func helperGCMLinearOracle(tb testing.TB, victim *GCMTruncatedMACVictim, sealed []byte) ValidityOracle {
+	tb.Helper()
+	var (
+		h      = victim.gcm.H()
+		n      = (len(sealed) - victim.TagSize()) / 16
+		powers = make(map[int]GF128)
+	)
+	return ValidityOracleFunc(func(forged []byte) (bool, error) {
+		if len(forged) != len(sealed) || !bytes.Equal(forged[16*n:], sealed[16*n:]) {
+			return false, errors.New("only ciphertext blocks can change")
+		}
+
+		// Block j multiplies H^(n+1-j). Skip over unchanged runs.
+		var d GF128
+		const chunk = 4096
+		for off := 0; off < 16*n; off += chunk {
+			end := off + chunk
+			if end > 16*n {
+				end = 16 * n
+			}
+			if bytes.Equal(forged[off:end], sealed[off:end]) {
+				continue
+			}
+			for i := off; i < end; i += 16 {
+				c := GF128FromBytes(forged[i : i+16]).Add(GF128FromBytes(sealed[i : i+16]))
+				if c == (GF128{}) {
+					continue
+				}
+				e := n + 1 - i/16
+				p, ok := powers[e]
+				if !ok {
+					p = h.Exp(big.NewInt(int64(e)))
+					powers[e] = p
+				}
+				d = d.Add(c.Mul(p))
+			}
+		}
+
+		ok := bytes.Equal(d.Bytes()[:victim.TagSize()], make([]byte, victim.TagSize()))
+		if ok && !victim.Verify(forged) {
+			tb.Errorf("linear oracle accepted a forgery the victim rejects")
+		}
+		return ok, nil
+	})
+}
@@ -0,0 +1,286 @@
+package cryptopals
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"io"
+)
+
+// RepeatingXORStream is a cipher.Stream for repeating-key XOR. It
+// remembers its position in the key between calls, so it works on
+// data in pieces.
+type RepeatingXORStream struct {
+	key []byte
+	pos int
+}
+
+// NewRepeatingXORStream returns a new RepeatingXORStream. It panics if
+// the key is empty.
+func NewRepeatingXORStream(key []byte) *RepeatingXORStream {
+	if len(key) == 0 {
+		panic("empty repeating-XOR key")
+	}
+	return &RepeatingXORStream{key: append([]byte(nil), key...)}
+}
+
+// XORKeyStream XORs src with the key, carrying on from where the last
+// call left off, and writes the result to dst. Like any cipher.Stream,
+// dst and src may overlap entirely.
+func (s *RepeatingXORStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	for i, c := range src {
+		dst[i] = c ^ s.key[s.pos]
+		s.pos = (s.pos + 1) % len(s.key)
+	}
+}
+
+// NewRepeatingXORReader returns a reader that XORs everything read
+// from r with a repeating key. Use cipher.StreamWriter with a
+// RepeatingXORStream to do the same while writing.
+func NewRepeatingXORReader(r io.Reader, key []byte) io.Reader {
+	return cipher.StreamReader{S: NewRepeatingXORStream(key), R: r}
+}
+
+// ecbMode is a cipher.BlockMode for ECB.
+type ecbMode struct {
+	b       cipher.Block
+	decrypt bool
+}
+
+// NewECBEncrypter returns a cipher.BlockMode that encrypts with b in
+// ECB mode.
+func NewECBEncrypter(b cipher.Block) cipher.BlockMode {
+	return &ecbMode{b: b}
+}
+
+// NewECBDecrypter returns a cipher.BlockMode that decrypts with b in
+// ECB mode.
+func NewECBDecrypter(b cipher.Block) cipher.BlockMode {
+	return &ecbMode{b: b, decrypt: true}
+}
+
+func (m *ecbMode) BlockSize() int {
+	return m.b.BlockSize()
+}
+
+func (m *ecbMode) CryptBlocks(dst, src []byte) {
+	bs := checkCryptBlocks(m.b, dst, src)
+	for i := 0; i < len(src); i += bs {
+		if m.decrypt {
+			m.b.Decrypt(dst[i:i+bs], src[i:i+bs])
+		} else {
+			m.b.Encrypt(dst[i:i+bs], src[i:i+bs])
+		}
+	}
+}
+
+// cbcMode is a cipher.BlockMode for CBC. It chains across calls, so
+// a message can be processed in pieces.
+type cbcMode struct {
+	b       cipher.Block
+	prev    []byte
+	tmp     []byte
+	decrypt bool
+}
+
+// NewCBCEncrypter returns a cipher.BlockMode that encrypts with b in
+// CBC mode. It panics if the IV isn't one block long.
+func NewCBCEncrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return newCBCMode(b, iv, false)
+}
+
+// NewCBCDecrypter returns a cipher.BlockMode that decrypts with b in
+// CBC mode. It panics if the IV isn't one block long.
+func NewCBCDecrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return newCBCMode(b, iv, true)
+}
+
+func newCBCMode(b cipher.Block, iv []byte, decrypt bool) *cbcMode {
+	if len(iv) != b.BlockSize() {
+		panic("invalid IV")
+	}
+	return &cbcMode{
+		b:       b,
+		prev:    append([]byte(nil), iv...),
+		tmp:     make([]byte, b.BlockSize()),
+		decrypt: decrypt,
+	}
+}
+
+func (m *cbcMode) BlockSize() int {
+	return m.b.BlockSize()
+}
+
+func (m *cbcMode) CryptBlocks(dst, src []byte) {
+	bs := checkCryptBlocks(m.b, dst, src)
+	for i := 0; i < len(src); i += bs {
+		var (
+			in  = src[i : i+bs]
+			out = dst[i : i+bs]
+		)
+		if m.decrypt {
+			// Save the ciphertext first, in case dst is src.
+			copy(m.tmp, in)
+			m.b.Decrypt(out, in)
+			for j := range out {
+				out[j] ^= m.prev[j]
+			}
+			m.prev, m.tmp = m.tmp, m.prev
+		} else {
+			for j := range m.prev {
+				m.prev[j] ^= in[j]
+			}
+			m.b.Encrypt(out, m.prev)
+			copy(m.prev, out)
+		}
+	}
+}
+
+// checkCryptBlocks checks the arguments to CryptBlocks the same way
+// crypto/cipher does, and returns the block size.
+func checkCryptBlocks(b cipher.Block, dst, src []byte) int {
+	bs := b.BlockSize()
+	if len(src)%bs != 0 {
+		panic("input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	return bs
+}
+
+// blockModeWriter is the io.WriteCloser from NewBlockModeWriter.
+type blockModeWriter struct {
+	w    io.Writer
+	mode cipher.BlockMode
+	buf  []byte
+	err  error
+}
+
+// NewBlockModeWriter returns a writer that encrypts what's written to
+// it with mode, and writes the ciphertext to w as whole blocks become
+// available. Close pads the rest with PKCS#7 and writes the final
+// blocks, but doesn't close w.
+func NewBlockModeWriter(w io.Writer, mode cipher.BlockMode) io.WriteCloser {
+	return &blockModeWriter{w: w, mode: mode}
+}
+
+func (w *blockModeWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	var (
+		bs   = w.mode.BlockSize()
+		held = len(w.buf)
+	)
+	w.buf = append(w.buf, p...)
+	n := len(w.buf) - len(w.buf)%bs
+	if m, err := w.flush(w.buf[:n]); err != nil {
+		// Only the whole blocks that were written count, less
+		// whatever was held over from earlier writes.
+		m -= m%bs + held
+		if m < 0 {
+			m = 0
+		}
+		return m, err
+	}
+	w.buf = append(w.buf[:0], w.buf[n:]...)
+	return len(p), nil
+}
+
+func (w *blockModeWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if _, err := w.flush(PKCS7Pad(w.buf, w.mode.BlockSize())); err != nil {
+		return err
+	}
+	w.err = fmt.Errorf("write to closed writer")
+	return nil
+}
+
+// flush encrypts b in place and writes it. It returns how many bytes
+// were written.
+func (w *blockModeWriter) flush(b []byte) (int, error) {
+	w.mode.CryptBlocks(b, b)
+	n, err := w.w.Write(b)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// blockModeReaderChunk is how much ciphertext a blockModeReader reads
+// at a time.
+const blockModeReaderChunk = 32 * 1024
+
+// blockModeReader is the io.Reader from NewBlockModeReader.
+type blockModeReader struct {
+	r     io.Reader
+	mode  cipher.BlockMode
+	chunk []byte
+	buf   []byte // Ciphertext that isn't a whole block yet.
+	out   []byte // Plaintext ready to be read.
+	last  []byte // The last block decrypted, which might be padding.
+	err   error
+}
+
+// NewBlockModeReader returns a reader that decrypts what it reads
+// from r with mode. It holds back the last block until r returns
+// io.EOF, and then strips its PKCS#7 padding. If the ciphertext
+// isn't whole blocks, or the padding is invalid, reading fails at the
+// end.
+func NewBlockModeReader(r io.Reader, mode cipher.BlockMode) io.Reader {
+	return &blockModeReader{r: r, mode: mode}
+}
+
+func (r *blockModeReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// fill reads more ciphertext, and decrypts as much of it as it can.
+func (r *blockModeReader) fill() {
+	if r.chunk == nil {
+		r.chunk = make([]byte, blockModeReaderChunk)
+	}
+	n, err := r.r.Read(r.chunk)
+	r.buf = append(r.buf, r.chunk[:n]...)
+
+	bs := r.mode.BlockSize()
+
+	if m := len(r.buf) - len(r.buf)%bs; m > 0 {
+		pt := make([]byte, m)
+		r.mode.CryptBlocks(pt, r.buf[:m])
+		r.buf = append(r.buf[:0], r.buf[m:]...)
+		r.out = append(r.last, pt[:m-bs]...)
+		r.last = pt[m-bs:]
+	}
+
+	switch {
+	case err == io.EOF:
+		if len(r.buf) != 0 || r.last == nil {
+			r.err = fmt.Errorf("ciphertext isn't whole blocks")
+			return
+		}
+		pt, err := PKCS7Validate(r.last)
+		if err != nil {
+			r.err = err
+			return
+		}
+		r.out = append(r.out, pt...)
+		r.last = nil
+		r.err = io.EOF
+	case err != nil:
+		r.err = err
+	}
+}
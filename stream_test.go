@@ -0,0 +1,232 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"errors"
+	"io"
+	"math/bits"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRepeatingXORReader(t *testing.T) {
+	t.Parallel()
+	var (
+		pt  = []byte("Burning 'em, if you ain't quick and nimble\nI go crazy when I hear a cymbal")
+		key = []byte("ICE")
+	)
+
+	got, err := io.ReadAll(NewRepeatingXORReader(iotest.OneByteReader(bytes.NewReader(pt)), key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := RepeatingXOR(pt, key); !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+// toyBlock is a deliberately weak block cipher with 8-bit blocks.
+type toyBlock byte
+
+func (toyBlock) BlockSize() int { return 1 }
+
+func (k toyBlock) Encrypt(dst, src []byte) {
+	dst[0] = bits.RotateLeft8(src[0]^byte(k), 3) + byte(k)
+}
+
+func (k toyBlock) Decrypt(dst, src []byte) {
+	dst[0] = bits.RotateLeft8(src[0]-byte(k), -3) ^ byte(k)
+}
+
+func TestBlockModes(t *testing.T) {
+	t.Parallel()
+	pt := HelperReadFileBase64(t, "testdata/10.txt")[:16*20]
+	a, err := aes.NewCipher(bytes.Repeat([]byte{2}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, b := range map[string]cipher.Block{
+		"AES": a,
+		"DES": d,
+		"toy": toyBlock(0x5c),
+	} {
+		var (
+			iv    = bytes.Repeat([]byte{3}, b.BlockSize())
+			ecbCT = make([]byte, len(pt))
+			cbcCT = make([]byte, len(pt))
+		)
+		for i := 0; i < len(pt); i += b.BlockSize() {
+			b.Encrypt(ecbCT[i:], pt[i:])
+		}
+		cipher.NewCBCEncrypter(b, iv).CryptBlocks(cbcCT, pt)
+		cbc := NewCBCCipherFromBlock(b)
+		cbc.SetIV(iv)
+
+		cases := []struct {
+			name     string
+			enc, dec cipher.BlockMode
+			want     []byte
+		}{
+			{"ECB", NewECBEncrypter(b), NewECBDecrypter(b), ecbCT},
+			{"ECBCipher", NewECBCipherFromBlock(b), NewECBDecrypter(b), ecbCT},
+			{"CBC", NewCBCEncrypter(b, iv), NewCBCDecrypter(b, iv), cbcCT},
+			{"CBCCipher", cbc, NewCBCDecrypter(b, iv), cbcCT},
+		}
+		for _, tc := range cases {
+			// In place, and in pieces.
+			buf := append([]byte(nil), pt...)
+			tc.enc.CryptBlocks(buf[:48], buf[:48])
+			tc.enc.CryptBlocks(buf[48:], buf[48:])
+			if !bytes.Equal(buf, tc.want) {
+				t.Errorf("%s %s: got %x, want %x", name, tc.name, buf, tc.want)
+			}
+			tc.dec.CryptBlocks(buf[:16], buf[:16])
+			tc.dec.CryptBlocks(buf[16:], buf[16:])
+			if !bytes.Equal(buf, pt) {
+				t.Errorf("%s %s: got %x, want %x", name, tc.name, buf, pt)
+			}
+		}
+
+		// SetIV starts a new message.
+		cbc.SetIV(iv)
+		buf := make([]byte, len(pt))
+		cbc.CryptBlocks(buf, pt)
+		if !bytes.Equal(buf, cbcCT) {
+			t.Errorf("%s CBCCipher after SetIV: got %x, want %x", name, buf, cbcCT)
+		}
+
+		// The slice-based methods agree with the block modes.
+		if got := NewECBCipherFromBlock(b).Decrypt(ecbCT); !bytes.Equal(got, pt) {
+			t.Errorf("%s ECBCipher: got %x, want %x", name, got, pt)
+		}
+		got, err := NewCBCCipherFromBlock(b).Decrypt(cbcCT, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("%s CBCCipher: got %x, want %x", name, got, pt)
+		}
+	}
+}
+
+func TestBlockModeWriter(t *testing.T) {
+	t.Parallel()
+	var (
+		key = bytes.Repeat([]byte{2}, 16)
+		iv  = bytes.Repeat([]byte{3}, 16)
+	)
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cbc, err := NewCBCCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 70; n += 7 {
+		pt := bytes.Repeat([]byte("yellow submarine"), 5)[:n]
+		want, err := cbc.Encrypt(PKCS7Pad(pt, 16), iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ct bytes.Buffer
+		w := NewBlockModeWriter(&ct, NewCBCEncrypter(b, iv))
+		for i := 0; i < n; i += 5 {
+			j := i + 5
+			if j > n {
+				j = n
+			}
+			if _, err := w.Write(pt[i:j]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ct.Bytes(), want) {
+			t.Errorf("len %d: got %x, want %x", n, ct.Bytes(), want)
+		}
+
+		r := NewBlockModeReader(iotest.HalfReader(bytes.NewReader(want)), NewCBCDecrypter(b, iv))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("len %d: %v", n, err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("len %d: got %x, want %x", n, got, pt)
+		}
+	}
+}
+
+// shortWriter accepts n bytes, and then fails.
+type shortWriter struct {
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("short write")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestBlockModeWriter_Short(t *testing.T) {
+	t.Parallel()
+	b, err := aes.NewCipher(bytes.Repeat([]byte{2}, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 bytes are held back, then 22 of the next 30 fill two
+	// blocks, but only the first is written in full. 6 bytes of it
+	// came from the second write.
+	w := NewBlockModeWriter(&shortWriter{n: 20}, NewECBEncrypter(b))
+	if n, err := w.Write(make([]byte, 10)); n != 10 || err != nil {
+		t.Fatalf("got %d, %v, want 10, nil", n, err)
+	}
+	if n, err := w.Write(make([]byte, 30)); n != 6 || err == nil {
+		t.Errorf("got %d, %v, want 6 and an error", n, err)
+	}
+	if n, err := w.Write(make([]byte, 30)); n != 0 || err == nil {
+		t.Errorf("after failing: got %d, %v, want 0 and an error", n, err)
+	}
+}
+
+func TestBlockModeReader_Invalid(t *testing.T) {
+	t.Parallel()
+	key := bytes.Repeat([]byte{2}, 16)
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecb, err := NewECBCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]byte{
+		"empty":       nil,
+		"partial":     make([]byte, 20),
+		"bad padding": ecb.Encrypt(append(bytes.Repeat([]byte{'A'}, 13), 1, 2, 3)),
+		"zero pad":    ecb.Encrypt(make([]byte, 16)),
+	}
+	for name, ct := range cases {
+		_, err := io.ReadAll(NewBlockModeReader(bytes.NewReader(ct), NewECBDecrypter(b)))
+		if err == nil || err == io.EOF {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}